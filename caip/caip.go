@@ -0,0 +1,148 @@
+// Package caip parse, validate and format CAIP-2 chain ids, CAIP-10 account ids and CAIP-25 namespaces
+package caip
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/libs4go/errors"
+)
+
+// EIP155 evm chains namespace
+const EIP155 = "eip155"
+
+var (
+	namespaceRegexp = regexp.MustCompile(`^[-a-z0-9]{3,8}$`)
+	referenceRegexp = regexp.MustCompile(`^[-_a-zA-Z0-9]{1,32}$`)
+	addressRegexp   = regexp.MustCompile(`^[-.%a-zA-Z0-9]{1,128}$`)
+)
+
+// ChainID CAIP-2 chain id, eg. eip155:1
+type ChainID struct {
+	Namespace string
+	Reference string
+}
+
+// NewChainID create chain id and validate namespace and reference
+func NewChainID(namespace string, reference string) (*ChainID, error) {
+	if !namespaceRegexp.MatchString(namespace) {
+		return nil, errors.Wrap(ErrChainID, "invalid namespace %s", namespace)
+	}
+
+	if !referenceRegexp.MatchString(reference) {
+		return nil, errors.Wrap(ErrChainID, "invalid reference %s", reference)
+	}
+
+	return &ChainID{Namespace: namespace, Reference: reference}, nil
+}
+
+// ParseChainID parse CAIP-2 chain id string
+func ParseChainID(str string) (*ChainID, error) {
+	parts := strings.Split(str, ":")
+
+	if len(parts) != 2 {
+		return nil, errors.Wrap(ErrChainID, "%s expect namespace:reference", str)
+	}
+
+	return NewChainID(parts[0], parts[1])
+}
+
+// EIP155ChainID create eip155 chain id of numeric evm chain id
+func EIP155ChainID(chainID int64) *ChainID {
+	return &ChainID{Namespace: EIP155, Reference: strconv.FormatInt(chainID, 10)}
+}
+
+// EIP155 get the numeric evm chain id of eip155 chain id
+func (id *ChainID) EIP155() (int64, error) {
+	if id.Namespace != EIP155 {
+		return 0, errors.Wrap(ErrChainID, "%s is not eip155 chain", id)
+	}
+
+	chainID, err := strconv.ParseInt(id.Reference, 10, 64)
+
+	if err != nil || chainID <= 0 {
+		return 0, errors.Wrap(ErrChainID, "%s reference is not positive integer", id)
+	}
+
+	return chainID, nil
+}
+
+// String implement Stringer
+func (id *ChainID) String() string {
+	return id.Namespace + ":" + id.Reference
+}
+
+// MarshalText implement encoding.TextMarshaler
+func (id *ChainID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implement encoding.TextUnmarshaler
+func (id *ChainID) UnmarshalText(text []byte) error {
+	parsed, err := ParseChainID(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*id = *parsed
+
+	return nil
+}
+
+// AccountID CAIP-10 account id, eg. eip155:1:0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb
+type AccountID struct {
+	Chain   ChainID
+	Address string
+}
+
+// NewAccountID create account id and validate address
+func NewAccountID(chain *ChainID, address string) (*AccountID, error) {
+	if !addressRegexp.MatchString(address) {
+		return nil, errors.Wrap(ErrAccountID, "invalid address %s", address)
+	}
+
+	return &AccountID{Chain: *chain, Address: address}, nil
+}
+
+// ParseAccountID parse CAIP-10 account id string
+func ParseAccountID(str string) (*AccountID, error) {
+	index := strings.LastIndex(str, ":")
+
+	if index < 0 {
+		return nil, errors.Wrap(ErrAccountID, "%s expect chain_id:address", str)
+	}
+
+	chain, err := ParseChainID(str[:index])
+
+	if err != nil {
+		return nil, errors.Wrap(ErrAccountID, "%s chain id error", str)
+	}
+
+	return NewAccountID(chain, str[index+1:])
+}
+
+// String implement Stringer
+func (id *AccountID) String() string {
+	return fmt.Sprintf("%s:%s", id.Chain.String(), id.Address)
+}
+
+// MarshalText implement encoding.TextMarshaler
+func (id *AccountID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implement encoding.TextUnmarshaler
+func (id *AccountID) UnmarshalText(text []byte) error {
+	parsed, err := ParseAccountID(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*id = *parsed
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package caip
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainID(t *testing.T) {
+	id, err := ParseChainID("eip155:137")
+
+	require.NoError(t, err)
+	require.Equal(t, "eip155", id.Namespace)
+
+	chainID, err := id.EIP155()
+
+	require.NoError(t, err)
+	require.Equal(t, int64(137), chainID)
+	require.Equal(t, "eip155:137", EIP155ChainID(137).String())
+
+	id, err = ParseChainID("cosmos:cosmoshub-4")
+
+	require.NoError(t, err)
+
+	_, err = id.EIP155()
+
+	require.True(t, errors.Is(err, ErrChainID))
+
+	for _, invalid := range []string{"eip155", "EIP155:1", "ab:1", "eip155:", "eip155:1:2"} {
+		_, err := ParseChainID(invalid)
+
+		require.True(t, errors.Is(err, ErrChainID), invalid)
+	}
+}
+
+func TestAccountID(t *testing.T) {
+	const str = "eip155:1:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb"
+
+	id, err := ParseAccountID(str)
+
+	require.NoError(t, err)
+	require.Equal(t, "eip155:1", id.Chain.String())
+	require.Equal(t, "0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb", id.Address)
+	require.Equal(t, str, id.String())
+
+	buff, err := json.Marshal([]*AccountID{id})
+
+	require.NoError(t, err)
+
+	var ids []*AccountID
+
+	require.NoError(t, json.Unmarshal(buff, &ids))
+	require.Equal(t, id, ids[0])
+
+	for _, invalid := range []string{"0xab16", "eip155:0xab16", "eip155:1:", "eip155:1:0x#"} {
+		_, err := ParseAccountID(invalid)
+
+		require.True(t, errors.Is(err, ErrAccountID), invalid)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	var proposal *Proposal
+
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"requiredNamespaces": {
+			"eip155": {"chains": ["eip155:1", "eip155:137"], "methods": ["eth_sendTransaction"], "events": ["chainChanged"]},
+			"eip155:10": {"methods": ["personal_sign"], "events": []}
+		}
+	}`), &proposal))
+
+	require.NoError(t, proposal.Validate())
+
+	session := map[string]*SessionNamespace{
+		"eip155": {
+			Accounts: []string{"eip155:1:0xab16", "eip155:137:0xab16", "eip155:10:0xab16"},
+			Methods:  []string{"eth_sendTransaction", "personal_sign"},
+			Events:   []string{"chainChanged"},
+		},
+	}
+
+	require.NoError(t, ValidateSessionNamespaces(session))
+	require.NoError(t, Satisfy(proposal.RequiredNamespaces, session))
+	require.Equal(t, []string{"eip155:1", "eip155:137", "eip155:10"}, session["eip155"].ChainIDs())
+
+	session["eip155"].Accounts = session["eip155"].Accounts[:2]
+
+	require.True(t, errors.Is(Satisfy(proposal.RequiredNamespaces, session), ErrUnsupportedChains))
+
+	session["eip155"].Methods = nil
+
+	require.True(t, errors.Is(Satisfy(map[string]*ProposalNamespace{"eip155": {Methods: []string{"personal_sign"}}}, session), ErrUnsupportedMethods))
+
+	require.True(t, errors.Is(Satisfy(map[string]*ProposalNamespace{"solana": {}}, session), ErrUnsupportedNamespace))
+
+	require.True(t, errors.Is(ValidateProposalNamespaces(map[string]*ProposalNamespace{"eip155": {Chains: []string{"cosmos:hub"}}}), ErrNamespace))
+	require.True(t, errors.Is(ValidateProposalNamespaces(map[string]*ProposalNamespace{"eip155:1": {Chains: []string{"eip155:1"}}}), ErrNamespace))
+	require.True(t, errors.Is(ValidateSessionNamespaces(map[string]*SessionNamespace{"eip155": {Accounts: []string{"cosmos:hub:addr"}}}), ErrNamespace))
+	require.True(t, errors.Is(ValidateSessionNamespaces(map[string]*SessionNamespace{"eip155": {}}), ErrNamespace))
+}
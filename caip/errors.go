@@ -0,0 +1,17 @@
+package caip
+
+import "github.com/libs4go/errors"
+
+// ScopeOfAPIError .
+const errVendor = "caip"
+
+// errors
+var (
+	ErrChainID              = errors.New("CAIP-2 chain id format error", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrAccountID            = errors.New("CAIP-10 account id format error", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrNamespace            = errors.New("CAIP-25 namespace format error", errors.WithCode(-3), errors.WithVendor(errVendor))
+	ErrUnsupportedNamespace = errors.New("required namespace not supported", errors.WithCode(-4), errors.WithVendor(errVendor))
+	ErrUnsupportedChains    = errors.New("required chains not supported", errors.WithCode(-5), errors.WithVendor(errVendor))
+	ErrUnsupportedMethods   = errors.New("required methods not supported", errors.WithCode(-6), errors.WithVendor(errVendor))
+	ErrUnsupportedEvents    = errors.New("required events not supported", errors.WithCode(-7), errors.WithVendor(errVendor))
+)
@@ -0,0 +1,211 @@
+package caip
+
+import (
+	"strings"
+
+	"github.com/libs4go/errors"
+)
+
+// ProposalNamespace CAIP-25 proposal namespace, chains are omitted when the namespace key is a chain id
+type ProposalNamespace struct {
+	Chains  []string `json:"chains,omitempty"`
+	Methods []string `json:"methods"`
+	Events  []string `json:"events"`
+}
+
+// SessionNamespace CAIP-25 session namespace approved by wallet
+type SessionNamespace struct {
+	Chains   []string `json:"chains,omitempty"`
+	Accounts []string `json:"accounts"`
+	Methods  []string `json:"methods"`
+	Events   []string `json:"events"`
+}
+
+// Proposal CAIP-25 session proposal namespaces
+type Proposal struct {
+	RequiredNamespaces map[string]*ProposalNamespace `json:"requiredNamespaces"`
+	OptionalNamespaces map[string]*ProposalNamespace `json:"optionalNamespaces,omitempty"`
+}
+
+// Validate check required and optional namespaces
+func (proposal *Proposal) Validate() error {
+	if err := ValidateProposalNamespaces(proposal.RequiredNamespaces); err != nil {
+		return err
+	}
+
+	return ValidateProposalNamespaces(proposal.OptionalNamespaces)
+}
+
+// namespaceOf check namespace key is namespace or chain id, returns the namespace part
+func namespaceOf(key string) (string, []string, error) {
+	if !strings.Contains(key, ":") {
+		if !namespaceRegexp.MatchString(key) {
+			return "", nil, errors.Wrap(ErrNamespace, "invalid namespace key %s", key)
+		}
+
+		return key, nil, nil
+	}
+
+	chain, err := ParseChainID(key)
+
+	if err != nil {
+		return "", nil, errors.Wrap(ErrNamespace, "invalid namespace key %s", key)
+	}
+
+	return chain.Namespace, []string{key}, nil
+}
+
+func validateChains(key string, namespace string, chains []string) error {
+	for _, chain := range chains {
+		id, err := ParseChainID(chain)
+
+		if err != nil || id.Namespace != namespace {
+			return errors.Wrap(ErrNamespace, "namespace %s chain %s is not CAIP-2 chain id of namespace", key, chain)
+		}
+	}
+
+	return nil
+}
+
+// ValidateProposalNamespaces check namespace keys and chains
+func ValidateProposalNamespaces(namespaces map[string]*ProposalNamespace) error {
+	for key, proposal := range namespaces {
+		if proposal == nil {
+			return errors.Wrap(ErrNamespace, "namespace %s is nil", key)
+		}
+
+		namespace, keyChains, err := namespaceOf(key)
+
+		if err != nil {
+			return err
+		}
+
+		if keyChains != nil && len(proposal.Chains) != 0 {
+			return errors.Wrap(ErrNamespace, "namespace %s is chain id, expect no chains", key)
+		}
+
+		if err := validateChains(key, namespace, proposal.Chains); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateSessionNamespaces check namespace keys, chains and accounts
+func ValidateSessionNamespaces(namespaces map[string]*SessionNamespace) error {
+	for key, session := range namespaces {
+		if session == nil {
+			return errors.Wrap(ErrNamespace, "namespace %s is nil", key)
+		}
+
+		namespace, _, err := namespaceOf(key)
+
+		if err != nil {
+			return err
+		}
+
+		if len(session.Accounts) == 0 {
+			return errors.Wrap(ErrNamespace, "namespace %s expect accounts", key)
+		}
+
+		for _, account := range session.Accounts {
+			id, err := ParseAccountID(account)
+
+			if err != nil || id.Chain.Namespace != namespace {
+				return errors.Wrap(ErrNamespace, "namespace %s account %s is not CAIP-10 account id of namespace", key, account)
+			}
+		}
+
+		if err := validateChains(key, namespace, session.Chains); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChainIDs get the chains of proposal namespace key
+func (proposal *ProposalNamespace) ChainIDs(key string) []string {
+	if strings.Contains(key, ":") {
+		return []string{key}
+	}
+
+	return proposal.Chains
+}
+
+// ChainIDs get the chains of session namespace, derived from accounts if chains is empty
+func (session *SessionNamespace) ChainIDs() []string {
+	if len(session.Chains) != 0 {
+		return session.Chains
+	}
+
+	var chains []string
+
+	seen := make(map[string]bool)
+
+	for _, account := range session.Accounts {
+		index := strings.LastIndex(account, ":")
+
+		if index < 0 {
+			continue
+		}
+
+		chain := account[:index]
+
+		if !seen[chain] {
+			seen[chain] = true
+			chains = append(chains, chain)
+		}
+	}
+
+	return chains
+}
+
+// Satisfy check session namespaces cover required namespaces,
+// the returned error is one of the ErrUnsupported* errors
+func Satisfy(required map[string]*ProposalNamespace, session map[string]*SessionNamespace) error {
+	for key, proposal := range required {
+		namespace, _, err := namespaceOf(key)
+
+		if err != nil {
+			return err
+		}
+
+		approved, ok := session[namespace]
+
+		if !ok {
+			return errors.Wrap(ErrUnsupportedNamespace, "namespace %s not approved", key)
+		}
+
+		if !contains(approved.ChainIDs(), proposal.ChainIDs(key)) {
+			return errors.Wrap(ErrUnsupportedChains, "namespace %s chains not approved", key)
+		}
+
+		if !contains(approved.Methods, proposal.Methods) {
+			return errors.Wrap(ErrUnsupportedMethods, "namespace %s methods not approved", key)
+		}
+
+		if !contains(approved.Events, proposal.Events) {
+			return errors.Wrap(ErrUnsupportedEvents, "namespace %s events not approved", key)
+		}
+	}
+
+	return nil
+}
+
+func contains(set []string, items []string) bool {
+	index := make(map[string]bool)
+
+	for _, s := range set {
+		index[s] = true
+	}
+
+	for _, item := range items {
+		if !index[item] {
+			return false
+		}
+	}
+
+	return true
+}
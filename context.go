@@ -0,0 +1,164 @@
+package tun4go
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/libs4go/errors"
+)
+
+// ContextTunnel Tunnel object with context aware functions, which return ctx.Err() when ctx done.
+// Blocking read is interrupted only when transport is a ContextTransport, see WithContext
+type ContextTunnel interface {
+	Tunnel
+
+	// SendContext context aware Send
+	SendContext(ctx context.Context, msg []byte, transport Transport) error
+
+	// RecvContext context aware Recv
+	RecvContext(ctx context.Context, transport Transport) ([]byte, error)
+
+	// DisconnectContext context aware Disconnect
+	DisconnectContext(ctx context.Context, transport Transport) error
+
+	// ConnectContext context aware Connect
+	ConnectContext(ctx context.Context, transport Transport) error
+}
+
+// ContextTransport Transport with context aware read/write
+type ContextTransport interface {
+	Transport
+	ReadContext(ctx context.Context) ([]byte, error)
+	WriteContext(ctx context.Context, buff []byte) error
+}
+
+// ReadContext read transport with ctx if it is a ContextTransport, otherwise check ctx before blocking read
+func ReadContext(ctx context.Context, transport Transport) ([]byte, error) {
+	if ct, ok := transport.(ContextTransport); ok {
+		return ct.ReadContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return transport.Read()
+}
+
+// WriteContext write transport with ctx if it is a ContextTransport, otherwise check ctx before write
+func WriteContext(ctx context.Context, transport Transport, buff []byte) error {
+	if ct, ok := transport.(ContextTransport); ok {
+		return ct.WriteContext(ctx, buff)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return transport.Write(buff)
+}
+
+type readResult struct {
+	buff []byte
+	err  error
+}
+
+type contextTransport struct {
+	Transport
+	once      sync.Once
+	closeOnce sync.Once
+	result    chan *readResult
+	done      chan struct{}
+	err       error // terminal read error, set before result closed
+}
+
+// WithContext wrap transport as ContextTransport, return transport itself if it already is one.
+// The wrapper reads underlying transport in one background goroutine, msg read after ctx done is
+// kept for next read, so a cancelled read never loses msg. The goroutine exits after underlying Read
+// returns error, call Close to close underlying io.Closer transport and release it
+func WithContext(transport Transport) ContextTransport {
+	if ct, ok := transport.(ContextTransport); ok {
+		return ct
+	}
+
+	return &contextTransport{
+		Transport: transport,
+		result:    make(chan *readResult),
+		done:      make(chan struct{}),
+	}
+}
+
+func (transport *contextTransport) pump() {
+	for {
+		buff, err := transport.Transport.Read()
+
+		select {
+		case transport.result <- &readResult{buff: buff, err: err}:
+		case <-transport.done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read blocking read without deadline
+func (transport *contextTransport) Read() ([]byte, error) {
+	return transport.ReadContext(context.Background())
+}
+
+// ReadContext read next msg or return ctx.Err() when ctx done
+func (transport *contextTransport) ReadContext(ctx context.Context) ([]byte, error) {
+	transport.once.Do(func() {
+		go transport.pump()
+	})
+
+	select {
+	case result, ok := <-transport.result:
+		if !ok {
+			return nil, transport.err
+		}
+
+		if result.err != nil {
+			// keep report the terminal error for later reads
+			transport.err = result.err
+			close(transport.result)
+		}
+
+		return result.buff, result.err
+	case <-transport.done:
+		return nil, errors.Wrap(io.EOF, "read from closed transport")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WriteContext check ctx before write, underlying Write is not interruptible
+func (transport *contextTransport) WriteContext(ctx context.Context, buff []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return transport.Transport.Write(buff)
+}
+
+// Close stop background read and close underlying transport if it is an io.Closer
+func (transport *contextTransport) Close() error {
+	var err error
+
+	// never start background read after closed
+	transport.once.Do(func() {})
+
+	transport.closeOnce.Do(func() {
+		close(transport.done)
+
+		if closer, ok := transport.Transport.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+
+	return err
+}
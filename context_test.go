@@ -0,0 +1,74 @@
+package tun4go
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithContext(t *testing.T) {
+	underlying := newChanTransport()
+
+	transport := WithContext(underlying)
+
+	require.Equal(t, transport, WithContext(transport))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := transport.ReadContext(ctx)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// msg arrived after cancelled read must not be lost
+	require.NoError(t, transport.WriteContext(context.Background(), []byte("hello")))
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+
+	require.Equal(t, context.Canceled, transport.WriteContext(canceled(), []byte("hello")))
+
+	require.NoError(t, transport.(*contextTransport).Close())
+
+	_, err = transport.Read()
+
+	require.Error(t, err)
+}
+
+func TestReadWriteContext(t *testing.T) {
+	transport := newChanTransport()
+
+	// plain transport checks ctx before read and write
+	require.Equal(t, context.Canceled, WriteContext(canceled(), transport, []byte("hello")))
+
+	require.NoError(t, WriteContext(context.Background(), transport, []byte("hello")))
+
+	_, err := ReadContext(canceled(), transport)
+
+	require.Equal(t, context.Canceled, err)
+
+	buff, err := ReadContext(context.Background(), transport)
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+
+	// ContextTransport read is interrupted by ctx
+	ct := WithContext(transport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = ReadContext(ctx, ct)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
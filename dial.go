@@ -0,0 +1,127 @@
+package tun4go
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/libs4go/errors"
+)
+
+// TransportProvider Provider which can open a transport matching the tunnel uri
+type TransportProvider interface {
+	Provider
+
+	// NewTransport open transport for uri
+	NewTransport(uri string) (Transport, error)
+}
+
+type dialOptions struct {
+	params    Params
+	transport Transport
+}
+
+// DialOption Dial option
+type DialOption func(options *dialOptions)
+
+// WithParams add provider specific tunnel params, the uri is passed as "url" param
+func WithParams(params Params) DialOption {
+	return func(options *dialOptions) {
+		for k, v := range params {
+			options.params[k] = v
+		}
+	}
+}
+
+// WithTransport use transport instead of the one opened by TransportProvider
+func WithTransport(transport Transport) DialOption {
+	return func(options *dialOptions) {
+		options.transport = transport
+	}
+}
+
+// Dial create tunnel by uri scheme registered provider, open transport and connect it,
+// uri with @version suffix, e.g. wc:<topic>@2?..., prefers the provider registered for scheme@version
+func Dial(uri string, opts ...DialOption) (Tunnel, Transport, error) {
+	return DialContext(context.Background(), uri, opts...)
+}
+
+// DialContext context aware Dial
+func DialContext(ctx context.Context, uri string, opts ...DialOption) (Tunnel, Transport, error) {
+	options := &dialOptions{
+		params: Params{},
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	index := strings.Index(uri, ":")
+
+	if index <= 0 {
+		return nil, nil, errors.Wrap(ErrScheme, "uri %s without scheme", uri)
+	}
+
+	scheme := strings.ToLower(uri[:index])
+
+	provider, ok := lookupURIProvider(scheme, uri[index+1:])
+
+	if !ok {
+		return nil, nil, errors.Wrap(ErrProviderNotFound, "provider of scheme %s not found", scheme)
+	}
+
+	options.params["url"] = uri
+
+	tunnel, err := provider.New(options.params)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport := options.transport
+
+	if transport == nil {
+		transportProvider, ok := provider.(TransportProvider)
+
+		if !ok {
+			return nil, nil, errors.Wrap(ErrScheme, "provider %s can not open transport, use WithTransport", provider.Name())
+		}
+
+		transport, err = transportProvider.NewTransport(uri)
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if contextTunnel, ok := tunnel.(ContextTunnel); ok {
+		err = contextTunnel.ConnectContext(ctx, transport)
+	} else {
+		err = tunnel.Connect(transport)
+	}
+
+	if err != nil {
+		if closer, ok := transport.(io.Closer); ok && options.transport == nil {
+			closer.Close()
+		}
+
+		return nil, nil, err
+	}
+
+	return tunnel, transport, nil
+}
+
+// lookupURIProvider lookup provider registered for scheme@version first, then the one for scheme
+func lookupURIProvider(scheme string, rest string) (Provider, bool) {
+	if end := strings.IndexAny(rest, "?#"); end >= 0 {
+		rest = rest[:end]
+	}
+
+	if at := strings.LastIndex(rest, "@"); at >= 0 && at < len(rest)-1 {
+		if provider, ok := LookupScheme(scheme + "@" + rest[at+1:]); ok {
+			return provider, true
+		}
+	}
+
+	return LookupScheme(scheme)
+}
@@ -0,0 +1,40 @@
+package tun4go
+
+import (
+	"encoding/json"
+)
+
+// Encoding message envelope encoding, providers use it to frame envelopes on wire.
+// Struct fields are named by json tag in all encodings
+type Encoding interface {
+	// Encoding name
+	Name() string
+
+	// Marshal encode v
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decode data into v
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON default encoding name
+const JSON = "json"
+
+type jsonEncoding struct {
+}
+
+func (encoding *jsonEncoding) Name() string {
+	return JSON
+}
+
+func (encoding *jsonEncoding) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (encoding *jsonEncoding) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func init() {
+	RegisterEncoding(&jsonEncoding{})
+}
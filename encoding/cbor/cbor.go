@@ -0,0 +1,44 @@
+// Package cbor register CBOR (RFC 7049) tun4go.Encoding with name "cbor"
+package cbor
+
+import (
+	"github.com/libs4go/tun4go"
+	"github.com/ugorji/go/codec"
+)
+
+// Name encoding name
+const Name = "cbor"
+
+type cborEncoding struct {
+	handle *codec.CborHandle
+}
+
+func newCBOREncoding() *cborEncoding {
+	handle := &codec.CborHandle{}
+
+	handle.TypeInfos = codec.NewTypeInfos([]string{"json"})
+
+	return &cborEncoding{
+		handle: handle,
+	}
+}
+
+func (encoding *cborEncoding) Name() string {
+	return Name
+}
+
+func (encoding *cborEncoding) Marshal(v interface{}) ([]byte, error) {
+	var buff []byte
+
+	err := codec.NewEncoderBytes(&buff, encoding.handle).Encode(v)
+
+	return buff, err
+}
+
+func (encoding *cborEncoding) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, encoding.handle).Decode(v)
+}
+
+func init() {
+	tun4go.RegisterEncoding(newCBOREncoding())
+}
@@ -0,0 +1,46 @@
+// Package msgpack register MessagePack tun4go.Encoding with name "msgpack"
+package msgpack
+
+import (
+	"github.com/libs4go/tun4go"
+	"github.com/ugorji/go/codec"
+)
+
+// Name encoding name
+const Name = "msgpack"
+
+type msgpackEncoding struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackEncoding() *msgpackEncoding {
+	handle := &codec.MsgpackHandle{}
+
+	handle.TypeInfos = codec.NewTypeInfos([]string{"json"})
+	// use str8 and bin format family
+	handle.WriteExt = true
+
+	return &msgpackEncoding{
+		handle: handle,
+	}
+}
+
+func (encoding *msgpackEncoding) Name() string {
+	return Name
+}
+
+func (encoding *msgpackEncoding) Marshal(v interface{}) ([]byte, error) {
+	var buff []byte
+
+	err := codec.NewEncoderBytes(&buff, encoding.handle).Encode(v)
+
+	return buff, err
+}
+
+func (encoding *msgpackEncoding) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, encoding.handle).Decode(v)
+}
+
+func init() {
+	tun4go.RegisterEncoding(newMsgpackEncoding())
+}
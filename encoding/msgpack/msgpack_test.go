@@ -0,0 +1,30 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+type envelope struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	encoding, err := tun4go.GetEncoding(Name)
+
+	require.NoError(t, err)
+	require.Equal(t, Name, encoding.Name())
+
+	buff, err := encoding.Marshal(&envelope{Topic: "a", Payload: []byte{0x00, 0xff}})
+
+	require.NoError(t, err)
+
+	var msg *envelope
+
+	require.NoError(t, encoding.Unmarshal(buff, &msg))
+	require.Equal(t, "a", msg.Topic)
+	require.Equal(t, []byte{0x00, 0xff}, msg.Payload)
+}
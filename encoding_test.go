@@ -0,0 +1,23 @@
+package tun4go
+
+import (
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEncoding(t *testing.T) {
+	encoding, err := GetEncoding(JSON)
+
+	require.NoError(t, err)
+
+	buff, err := encoding.Marshal(map[string]string{"topic": "a"})
+
+	require.NoError(t, err)
+	require.Equal(t, `{"topic":"a"}`, string(buff))
+
+	_, err = GetEncoding("unknown")
+
+	require.True(t, errors.Is(err, ErrEncodingNotFound))
+}
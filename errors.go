@@ -0,0 +1,13 @@
+package tun4go
+
+import "github.com/libs4go/errors"
+
+const errVendor = "tun4go"
+
+// errors
+var (
+	ErrEncodingNotFound = errors.New("encoding not found", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrProviderNotFound = errors.New("provider not found", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrScheme           = errors.New("uri scheme error", errors.WithCode(-3), errors.WithVendor(errVendor))
+	ErrStatus           = errors.New("tunnel status error", errors.WithCode(-4), errors.WithVendor(errVendor))
+)
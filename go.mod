@@ -10,4 +10,6 @@ require (
 	github.com/libs4go/sdi4go v0.0.0-20191107032536-9900892950bc
 	github.com/libs4go/slf4go v0.0.4
 	github.com/stretchr/testify v1.4.0
+	github.com/ugorji/go/codec v1.2.6
+	golang.org/x/crypto v0.10.0
 )
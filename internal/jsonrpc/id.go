@@ -0,0 +1,53 @@
+// Package jsonrpc json rpc helpers shared by wc providers
+package jsonrpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// NewID create json rpc id with the wc js client layout: unix ms * 1000 + 3 random digits
+func NewID() int64 {
+	extra, err := rand.Int(rand.Reader, big.NewInt(1000))
+
+	if err != nil {
+		extra = big.NewInt(0)
+	}
+
+	return time.Now().UnixNano()/int64(time.Millisecond)*1000 + extra.Int64()
+}
+
+// SameID check raw json rpc id is id by value, raw id is json number or string of decimal number,
+// peers may echo the id in another number format or as string
+func SameID(raw json.RawMessage, id int64) bool {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return false
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n == id
+		}
+
+		f, err := v.Float64()
+
+		return err == nil && f == float64(id)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+
+		return err == nil && n == id
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewID(t *testing.T) {
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+
+	id := NewID()
+
+	require.True(t, id/1000 >= ms)
+	require.True(t, id/1000 <= time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+func TestSameID(t *testing.T) {
+	require.True(t, SameID(json.RawMessage(`1234`), 1234))
+	require.True(t, SameID(json.RawMessage(` 1.234e3 `), 1234))
+	require.True(t, SameID(json.RawMessage(`"1234"`), 1234))
+	require.False(t, SameID(json.RawMessage(`1235`), 1234))
+	require.False(t, SameID(json.RawMessage(`"abc"`), 1234))
+	require.False(t, SameID(json.RawMessage(`null`), 1234))
+	require.False(t, SameID(nil, 1234))
+}
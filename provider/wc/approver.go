@@ -0,0 +1,97 @@
+package wc
+
+import (
+	"context"
+)
+
+// SessionProposal decoded session request of peer dapp
+type SessionProposal struct {
+	PeerID   string      // dapp peer id
+	PeerMeta *ClientInfo // dapp metadata
+	ChainID  int64       // requested chain id, 0 if dapp not specified
+}
+
+// Decision approver decision of session request
+type Decision struct {
+	Approved bool     // approve or reject session
+	Accounts []string // approved accounts, must be subset of tunnel accounts, empty means all
+	ChainID  int64    // approved chain id, 0 means tunnel chain id
+	Reason   string   // reject reason sent to peer
+}
+
+// Approve create approve decision, empty accounts and zero chain id keep the tunnel session
+func Approve(accounts []string, chainID int64) *Decision {
+	return &Decision{Approved: true, Accounts: accounts, ChainID: chainID}
+}
+
+// Reject create reject decision with reason
+func Reject(reason string) *Decision {
+	return &Decision{Reason: reason}
+}
+
+// Approver decide session request of peer dapp, Approve may block until decided,
+// eg. waiting for ui confirmation, ctx is the Connect context
+type Approver interface {
+	Approve(ctx context.Context, proposal *SessionProposal) (*Decision, error)
+}
+
+// ApproverFunc function adapter of Approver
+type ApproverFunc func(ctx context.Context, proposal *SessionProposal) (*Decision, error)
+
+// Approve implement Approver
+func (f ApproverFunc) Approve(ctx context.Context, proposal *SessionProposal) (*Decision, error) {
+	return f(ctx, proposal)
+}
+
+// Prompt pending session request waiting for decision
+type Prompt struct {
+	Proposal *SessionProposal
+	decision chan *Decision
+}
+
+// Decide send the decision of prompt, only the first decision is taken
+func (prompt *Prompt) Decide(decision *Decision) {
+	select {
+	case prompt.decision <- decision:
+	default:
+	}
+}
+
+// PromptApprover approver forwarding session requests to Prompts channel, the decision is made
+// asynchronously by Prompt.Decide, eg. from ui goroutine
+type PromptApprover struct {
+	prompts chan *Prompt
+}
+
+// NewPromptApprover create prompt approver
+func NewPromptApprover() *PromptApprover {
+	return &PromptApprover{
+		prompts: make(chan *Prompt),
+	}
+}
+
+// Prompts get the pending session requests channel
+func (approver *PromptApprover) Prompts() <-chan *Prompt {
+	return approver.prompts
+}
+
+// Approve implement Approver, block until decided or ctx done
+func (approver *PromptApprover) Approve(ctx context.Context, proposal *SessionProposal) (*Decision, error) {
+	prompt := &Prompt{
+		Proposal: proposal,
+		decision: make(chan *Decision, 1),
+	}
+
+	select {
+	case approver.prompts <- prompt:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case decision := <-prompt.decision:
+		return decision, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
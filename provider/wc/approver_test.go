@@ -0,0 +1,133 @@
+package wc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/stretchr/testify/require"
+)
+
+const other = "0x0000000000000000000000000000000000000001"
+
+// connectWith connect dapp and wallet decided by approver, returns dapp and wallet connect errors
+func connectWith(t *testing.T, approver Approver) (Tunnel, Tunnel, error, error) {
+	dapp, err := New(&Options{
+		Role:       Dapp,
+		Bridge:     bridgeServer.URL(),
+		ChainID:    56,
+		ClientInfo: &ClientInfo{Name: "dapp"},
+	})
+
+	require.NoError(t, err)
+
+	wallet, err := New(&Options{
+		URL:        dapp.HandshakeURL().String(),
+		Accounts:   []string{account, other},
+		ChainID:    1,
+		ClientInfo: &ClientInfo{Name: "wallet"},
+	})
+
+	require.NoError(t, err)
+
+	wallet.SetApprover(approver)
+
+	dappTransport, err := newWebSockTransport(dapp.HandshakeURL().String())
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() { dappTransport.Close() })
+
+	walletTransport, err := newWebSockTransport(dapp.HandshakeURL().String())
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() { walletTransport.Close() })
+
+	walletErr := make(chan error, 1)
+
+	go func() {
+		walletErr <- wallet.Connect(walletTransport)
+	}()
+
+	dappErr := dapp.Connect(dappTransport)
+
+	return dapp, wallet, dappErr, <-walletErr
+}
+
+func TestApprover(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	dapp, wallet, dappErr, walletErr := connectWith(t, ApproverFunc(func(ctx context.Context, proposal *SessionProposal) (*Decision, error) {
+		require.Equal(t, "dapp", proposal.PeerMeta.Name)
+		require.Equal(t, int64(56), proposal.ChainID)
+
+		return Approve([]string{other}, proposal.ChainID), nil
+	}))
+
+	require.NoError(t, dappErr)
+	require.NoError(t, walletErr)
+
+	accounts, chainID := dapp.Session()
+
+	require.Equal(t, []string{other}, accounts)
+	require.Equal(t, int64(56), chainID)
+
+	accounts, chainID = wallet.Session()
+
+	require.Equal(t, []string{other}, accounts)
+	require.Equal(t, int64(56), chainID)
+}
+
+func TestApproverReject(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	_, wallet, dappErr, walletErr := connectWith(t, ApproverFunc(func(ctx context.Context, proposal *SessionProposal) (*Decision, error) {
+		return Reject("unknown dapp"), nil
+	}))
+
+	require.True(t, errors.Is(dappErr, ErrRejected))
+	require.Contains(t, dappErr.Error(), "unknown dapp")
+	require.True(t, errors.Is(walletErr, ErrRejected))
+	require.Equal(t, Disconnected, wallet.Status())
+
+	// approved accounts must be owned by wallet
+	_, _, dappErr, walletErr = connectWith(t, ApproverFunc(func(ctx context.Context, proposal *SessionProposal) (*Decision, error) {
+		return Approve([]string{"0x0000000000000000000000000000000000000002"}, 0), nil
+	}))
+
+	require.True(t, errors.Is(dappErr, ErrRejected))
+	require.True(t, errors.Is(walletErr, ErrParams))
+}
+
+func TestPromptApprover(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	approver := NewPromptApprover()
+
+	go func() {
+		prompt := <-approver.Prompts()
+		prompt.Decide(Approve(nil, 0))
+	}()
+
+	dapp, _, dappErr, walletErr := connectWith(t, approver)
+
+	require.NoError(t, dappErr)
+	require.NoError(t, walletErr)
+
+	accounts, chainID := dapp.Session()
+
+	require.Equal(t, []string{account, other}, accounts)
+	require.Equal(t, int64(1), chainID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := approver.Approve(ctx, &SessionProposal{})
+
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,394 @@
+// Package bridge implement an embeddable wallet connect v1 bridge server
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+)
+
+type socketMessage struct {
+	Topic   string `json:"topic"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+	Silent  bool   `json:"silent,omitempty"`
+}
+
+type webhookRequest struct {
+	Topic   string `json:"topic"`
+	Webhook string `json:"webhook"`
+}
+
+type pending struct {
+	buff    []byte
+	expired time.Time
+}
+
+type wsClient struct {
+	conn        *websocket.Conn
+	writeLock   sync.Mutex
+	messageType int
+}
+
+func (client *wsClient) write(buff []byte) error {
+	client.writeLock.Lock()
+	defer client.writeLock.Unlock()
+
+	return client.conn.WriteMessage(client.messageType, buff)
+}
+
+type options struct {
+	ttl        time.Duration
+	queueLimit int
+	httpClient *http.Client
+	encoding   tun4go.Encoding
+}
+
+// Option bridge server option
+type Option func(options *options)
+
+// WithTTL set the lifetime of msg queued for offline subscriber, default is 24 hours
+func WithTTL(ttl time.Duration) Option {
+	return func(options *options) {
+		options.ttl = ttl
+	}
+}
+
+// WithQueueLimit set max queued msg number per topic, the oldest msg is dropped when overflow
+func WithQueueLimit(limit int) Option {
+	return func(options *options) {
+		options.queueLimit = limit
+	}
+}
+
+// WithHTTPClient set http client used to call push webhook
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(options *options) {
+		options.httpClient = httpClient
+	}
+}
+
+// WithEncoding set socket message encoding, default is json. Frames of binary encodings are sent as binary msg
+func WithEncoding(encoding tun4go.Encoding) Option {
+	return func(options *options) {
+		options.encoding = encoding
+	}
+}
+
+// Server wallet connect v1 bridge server
+type Server struct {
+	logger   slf4go.Logger
+	mutex    sync.Mutex
+	options  *options
+	upgrader websocket.Upgrader
+	subs     map[string]map[*wsClient]bool
+	queues   map[string][]*pending
+	webhooks map[string]string
+	listener net.Listener
+	server   *http.Server
+	clients  map[*wsClient]bool // connected websocket clients, closed by Close
+	closed   bool
+	wg       sync.WaitGroup // running websocket handlers
+	// websocket msg type used to send frames
+	messageType int
+}
+
+// New create bridge server
+func New(opts ...Option) *Server {
+	options := &options{
+		ttl:        24 * time.Hour,
+		queueLimit: 1024,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.encoding == nil {
+		options.encoding, _ = tun4go.GetEncoding(tun4go.JSON)
+	}
+
+	messageType := websocket.TextMessage
+
+	if options.encoding.Name() != tun4go.JSON {
+		messageType = websocket.BinaryMessage
+	}
+
+	return &Server{
+		logger:  slf4go.Get("wc-bridge"),
+		options: options,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		subs:        make(map[string]map[*wsClient]bool),
+		queues:      make(map[string][]*pending),
+		webhooks:    make(map[string]string),
+		clients:     make(map[*wsClient]bool),
+		messageType: messageType,
+	}
+}
+
+// Start listen on addr and serve in background, use "127.0.0.1:0" to pick a random port
+func (server *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return errors.Wrap(err, "listen on %s error", addr)
+	}
+
+	server.listener = listener
+	server.server = &http.Server{Handler: server}
+
+	go server.server.Serve(listener)
+
+	return nil
+}
+
+// URL get the bridge url of started server, returns empty string if server is not started
+func (server *Server) URL() string {
+	if server.listener == nil {
+		return ""
+	}
+
+	return "http://" + server.listener.Addr().String()
+}
+
+// Close stop the started server, close connected websocket clients and wait their handlers exit
+func (server *Server) Close() error {
+	if server.server == nil {
+		return nil
+	}
+
+	err := server.server.Close()
+
+	server.mutex.Lock()
+
+	server.closed = true
+
+	for client := range server.clients {
+		client.conn.Close()
+	}
+
+	server.mutex.Unlock()
+
+	server.wg.Wait()
+
+	return err
+}
+
+// ServeHTTP implement http.Handler
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case websocket.IsWebSocketUpgrade(r):
+		server.serveWebsocket(w, r)
+	case r.URL.Path == "/hello" && r.Method == http.MethodGet:
+		w.Write([]byte("Hello World, this is WalletConnect v1.0"))
+	case r.URL.Path == "/info" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"tun4go-bridge","version":"1.0"}`))
+	case r.URL.Path == "/subscribe" && r.Method == http.MethodPost:
+		server.serveSubscribe(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (server *Server) serveSubscribe(w http.ResponseWriter, r *http.Request) {
+	var request *webhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request == nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Topic == "" || request.Webhook == "" {
+		http.Error(w, "expect topic and webhook", http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	server.webhooks[request.Topic] = request.Webhook
+	server.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"success":true}`))
+}
+
+func (server *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := server.upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		server.logger.W("upgrade websocket error {@err}", err)
+		return
+	}
+
+	client := &wsClient{conn: conn, messageType: server.messageType}
+
+	server.mutex.Lock()
+
+	if server.closed {
+		server.mutex.Unlock()
+		conn.Close()
+		return
+	}
+
+	server.clients[client] = true
+	server.wg.Add(1)
+
+	server.mutex.Unlock()
+
+	defer func() {
+		server.unsubscribe(client)
+		conn.Close()
+
+		server.mutex.Lock()
+		delete(server.clients, client)
+		server.mutex.Unlock()
+
+		server.wg.Done()
+	}()
+
+	for {
+		_, buff, err := conn.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		var msg *socketMessage
+
+		if err := server.options.encoding.Unmarshal(buff, &msg); err != nil || msg == nil {
+			server.logger.W("skip invalid msg {@msg}", string(buff))
+			continue
+		}
+
+		switch msg.Type {
+		case "sub":
+			server.subscribe(msg.Topic, client)
+		case "pub":
+			server.publish(msg, buff)
+		default:
+			server.logger.W("skip unknown msg type {@type}", msg.Type)
+		}
+	}
+}
+
+func (server *Server) subscribe(topic string, client *wsClient) {
+	server.mutex.Lock()
+
+	clients, ok := server.subs[topic]
+
+	if !ok {
+		clients = make(map[*wsClient]bool)
+		server.subs[topic] = clients
+	}
+
+	clients[client] = true
+
+	queue := server.queues[topic]
+
+	delete(server.queues, topic)
+
+	server.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, p := range queue {
+		if now.After(p.expired) {
+			continue
+		}
+
+		if err := client.write(p.buff); err != nil {
+			server.logger.W("flush queued msg to topic {@topic} error {@err}", topic, err)
+			return
+		}
+	}
+}
+
+func (server *Server) unsubscribe(client *wsClient) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for topic, clients := range server.subs {
+		delete(clients, client)
+
+		if len(clients) == 0 {
+			delete(server.subs, topic)
+		}
+	}
+}
+
+func (server *Server) publish(msg *socketMessage, buff []byte) {
+	server.mutex.Lock()
+
+	var clients []*wsClient
+
+	for client := range server.subs[msg.Topic] {
+		clients = append(clients, client)
+	}
+
+	if len(clients) == 0 {
+		server.enqueue(msg.Topic, buff)
+	}
+
+	webhook := server.webhooks[msg.Topic]
+
+	server.mutex.Unlock()
+
+	for _, client := range clients {
+		if err := client.write(buff); err != nil {
+			server.logger.W("publish msg to topic {@topic} error {@err}", msg.Topic, err)
+		}
+	}
+
+	if webhook != "" && !msg.Silent {
+		go server.push(webhook, msg.Topic)
+	}
+}
+
+// enqueue must be called with server lock held
+func (server *Server) enqueue(topic string, buff []byte) {
+	now := time.Now()
+
+	queue := server.queues[topic][:0]
+
+	for _, p := range server.queues[topic] {
+		if now.Before(p.expired) {
+			queue = append(queue, p)
+		}
+	}
+
+	queue = append(queue, &pending{buff: buff, expired: now.Add(server.options.ttl)})
+
+	if len(queue) > server.options.queueLimit {
+		queue = queue[len(queue)-server.options.queueLimit:]
+	}
+
+	server.queues[topic] = queue
+}
+
+func (server *Server) push(webhook string, topic string) {
+	buff, err := json.Marshal(map[string]string{"topic": topic})
+
+	if err != nil {
+		return
+	}
+
+	rsp, err := server.options.httpClient.Post(webhook, "application/json", bytes.NewReader(buff))
+
+	if err != nil {
+		server.logger.W("call push webhook {@webhook} error {@err}", webhook, err)
+		return
+	}
+
+	rsp.Body.Close()
+}
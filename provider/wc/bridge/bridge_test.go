@@ -0,0 +1,188 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, opts ...Option) *Server {
+	server := New(opts...)
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	return server
+}
+
+func dial(t *testing.T, server *Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL(), "http", "ws", 1), nil)
+
+	require.NoError(t, err)
+
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg *socketMessage) {
+	buff, err := json.Marshal(msg)
+
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, buff))
+}
+
+func recv(t *testing.T, conn *websocket.Conn) *socketMessage {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, buff, err := conn.ReadMessage()
+
+	require.NoError(t, err)
+
+	var msg *socketMessage
+
+	require.NoError(t, json.Unmarshal(buff, &msg))
+
+	return msg
+}
+
+func TestPubSub(t *testing.T) {
+	server := startServer(t)
+	defer server.Close()
+
+	sub := dial(t, server)
+	defer sub.Close()
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	send(t, sub, &socketMessage{Topic: "a", Type: "sub"})
+
+	// sub is async, wait bridge register it
+	time.Sleep(50 * time.Millisecond)
+
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "hello"})
+
+	msg := recv(t, sub)
+
+	require.Equal(t, "a", msg.Topic)
+	require.Equal(t, "hello", msg.Payload)
+}
+
+func TestOfflineQueue(t *testing.T) {
+	server := startServer(t)
+	defer server.Close()
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "1"})
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "2"})
+
+	time.Sleep(50 * time.Millisecond)
+
+	sub := dial(t, server)
+	defer sub.Close()
+
+	send(t, sub, &socketMessage{Topic: "a", Type: "sub"})
+
+	require.Equal(t, "1", recv(t, sub).Payload)
+	require.Equal(t, "2", recv(t, sub).Payload)
+}
+
+func TestTTL(t *testing.T) {
+	server := startServer(t, WithTTL(50*time.Millisecond))
+	defer server.Close()
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "expired"})
+
+	time.Sleep(100 * time.Millisecond)
+
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "alive"})
+
+	time.Sleep(20 * time.Millisecond)
+
+	sub := dial(t, server)
+	defer sub.Close()
+
+	send(t, sub, &socketMessage{Topic: "a", Type: "sub"})
+
+	require.Equal(t, "alive", recv(t, sub).Payload)
+}
+
+func TestWebhook(t *testing.T) {
+	topics := make(chan string, 1)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]string
+		json.NewDecoder(r.Body).Decode(&request)
+		topics <- request["topic"]
+	}))
+
+	defer webhook.Close()
+
+	server := startServer(t)
+	defer server.Close()
+
+	rsp, err := http.Post(server.URL()+"/subscribe", "application/json",
+		strings.NewReader(`{"topic":"a","webhook":"`+webhook.URL+`"}`))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	rsp, err = http.Post(server.URL()+"/subscribe", "application/json", strings.NewReader(`{"topic":"a"}`))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "hello", Silent: true})
+	send(t, pub, &socketMessage{Topic: "a", Type: "pub", Payload: "hello"})
+
+	select {
+	case topic := <-topics:
+		require.Equal(t, "a", topic)
+	case <-time.After(time.Second):
+		require.Fail(t, "webhook not called")
+	}
+
+	select {
+	case <-topics:
+		require.Fail(t, "silent msg must not call webhook")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClose(t *testing.T) {
+	require.Equal(t, "", New().URL())
+
+	server := startServer(t)
+
+	conn := dial(t, server)
+	defer conn.Close()
+
+	send(t, conn, &socketMessage{Topic: "a", Type: "sub"})
+
+	require.NoError(t, server.Close())
+
+	// hijacked websocket connection is closed by server Close
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, _, err := conn.ReadMessage()
+
+	require.Error(t, err)
+
+	netErr, ok := err.(net.Error)
+
+	require.False(t, ok && netErr.Timeout(), "connection must be closed before read deadline")
+}
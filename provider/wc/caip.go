@@ -0,0 +1,86 @@
+package wc
+
+import (
+	"strings"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go/caip"
+)
+
+// CAIP2 convert numeric chain id to CAIP-2 eip155 chain id
+func CAIP2(chainID int64) string {
+	return caip.EIP155ChainID(chainID).String()
+}
+
+// ChainIDOf convert CAIP-2 eip155 chain id to numeric chain id
+func ChainIDOf(chain string) (int64, error) {
+	id, err := caip.ParseChainID(chain)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id.EIP155()
+}
+
+// CAIP10 convert accounts of numeric chain id to CAIP-10 account ids
+func CAIP10(accounts []string, chainID int64) []string {
+	chain := CAIP2(chainID)
+
+	var ids []string
+
+	for _, account := range accounts {
+		ids = append(ids, chain+":"+account)
+	}
+
+	return ids
+}
+
+// FromCAIP10 split CAIP-10 eip155 account ids to addresses and numeric chain id,
+// all accounts must be on the same chain
+func FromCAIP10(ids []string) ([]string, int64, error) {
+	var accounts []string
+	var chainID int64
+
+	for _, str := range ids {
+		id, err := caip.ParseAccountID(str)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		accountChainID, err := id.Chain.EIP155()
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if chainID != 0 && chainID != accountChainID {
+			return nil, 0, errors.Wrap(caip.ErrAccountID, "account %s is not on chain %s", str, CAIP2(chainID))
+		}
+
+		chainID = accountChainID
+		accounts = append(accounts, id.Address)
+	}
+
+	return accounts, chainID, nil
+}
+
+// namespaces convert session accounts and chain id to CAIP-25 eip155 session namespace
+func namespaces(accounts []string, chainID int64) map[string]*caip.SessionNamespace {
+	if len(accounts) == 0 || chainID <= 0 {
+		return nil
+	}
+
+	return map[string]*caip.SessionNamespace{
+		caip.EIP155: {
+			Chains:   []string{CAIP2(chainID)},
+			Accounts: CAIP10(accounts, chainID),
+		},
+	}
+}
+
+// isCAIP10 check account is in CAIP-10 form rather than plain address
+func isCAIP10(account string) bool {
+	return strings.Contains(account, ":")
+}
@@ -0,0 +1,118 @@
+package wc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentTunnel hammer Send, Recv, Update, Disconnect and Context, run with -race
+func TestConcurrentTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	const writers = 8
+	const msgs = 20
+
+	changed := make(chan struct{}, writers*msgs)
+
+	p.dapp.(Tunnel).OnSessionChanged(func(event *SessionEvent) {
+		// listeners may access the tunnel
+		p.dapp.(Tunnel).Session()
+		changed <- struct{}{}
+	})
+
+	walletRecv := make(chan error, 1)
+
+	go func() {
+		for {
+			if _, err := p.wallet.Recv(p.walletTransport); err != nil {
+				walletRecv <- err
+				return
+			}
+		}
+	}()
+
+	dappRecv := make(chan error, 1)
+
+	go func() {
+		for {
+			if _, err := p.dapp.Recv(p.dappTransport); err != nil {
+				dappRecv <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	// unexpected errors are reported by goroutines and checked after wg.Wait
+	errs := make(chan error, writers*msgs*4+1)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < msgs; j++ {
+				msg := fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","method":"eth_chainId","params":[]}`, i*msgs+j)
+
+				if err := p.dapp.Send([]byte(msg), p.dappTransport); err != nil && !errors.Is(err, ErrStatus) {
+					errs <- err
+				}
+
+				if err := p.wallet.(Tunnel).Update([]string{account}, int64(j+1), p.walletTransport); err != nil && !errors.Is(err, ErrStatus) {
+					errs <- err
+				}
+
+				if _, err := p.dapp.Context(); err != nil {
+					errs <- err
+				}
+
+				if _, err := p.wallet.Context(); err != nil {
+					errs <- err
+				}
+
+				p.dapp.(Tunnel).Status()
+				p.dapp.(Tunnel).Namespaces()
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		<-changed
+
+		if err := p.wallet.Disconnect(p.walletTransport); err != nil {
+			errs <- err
+		}
+	}()
+
+	wg.Wait()
+
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.True(t, errors.Is(<-dappRecv, ErrDisconnected))
+	require.Equal(t, Disconnected, p.dapp.(Tunnel).Status())
+	require.Equal(t, Disconnected, p.wallet.(Tunnel).Status())
+
+	// wallet Recv returns once the blocking read ends
+	p.walletTransport.Close()
+
+	require.Error(t, <-walletRecv)
+}
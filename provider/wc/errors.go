@@ -7,12 +7,18 @@ const errVendor = "wc"
 
 // errors
 var (
-	ErrURLKey       = errors.New("url key not found", errors.WithCode(-1), errors.WithVendor(errVendor))
-	ErrURLBridge    = errors.New("url bridge not found", errors.WithCode(-2), errors.WithVendor(errVendor))
-	ErrHMAC         = errors.New("hmac compare mismatch", errors.WithCode(-3), errors.WithVendor(errVendor))
-	ErrMessage      = errors.New("unexpect message", errors.WithCode(-4), errors.WithVendor(errVendor))
-	ErrFormat       = errors.New("message format error", errors.WithCode(-5), errors.WithVendor(errVendor))
-	ErrStatus       = errors.New("Tunnel status error", errors.WithCode(-6), errors.WithVendor(errVendor))
-	ErrParams       = errors.New("tunnel create params error", errors.WithCode(-7), errors.WithVendor(errVendor))
-	ErrDisconnected = errors.New("tunnel peer disconnect", errors.WithCode(-8), errors.WithVendor(errVendor))
+	ErrURLKey        = errors.New("url key not found", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrURLBridge     = errors.New("url bridge not found", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrHMAC          = errors.New("hmac compare mismatch", errors.WithCode(-3), errors.WithVendor(errVendor))
+	ErrMessage       = errors.New("unexpect message", errors.WithCode(-4), errors.WithVendor(errVendor))
+	ErrFormat        = errors.New("message format error", errors.WithCode(-5), errors.WithVendor(errVendor))
+	ErrStatus        = errors.New("Tunnel status error", errors.WithCode(-6), errors.WithVendor(errVendor))
+	ErrParams        = errors.New("tunnel create params error", errors.WithCode(-7), errors.WithVendor(errVendor))
+	ErrDisconnected  = errors.New("tunnel peer disconnect", errors.WithCode(-8), errors.WithVendor(errVendor))
+	ErrRejected      = errors.New("session rejected by peer", errors.WithCode(-9), errors.WithVendor(errVendor))
+	ErrSessionUpdate = errors.New("malformed session update", errors.WithCode(-10), errors.WithVendor(errVendor))
+	ErrClosed        = errors.New("transport closed", errors.WithCode(-11), errors.WithVendor(errVendor))
+	ErrPeerLost      = errors.New("tunnel peer lost", errors.WithCode(-12), errors.WithVendor(errVendor))
+	ErrTopicTaken    = errors.New("topic subscribed by other session", errors.WithCode(-13), errors.WithVendor(errVendor))
+	ErrQueueFull     = errors.New("session queue full", errors.WithCode(-14), errors.WithVendor(errVendor))
 )
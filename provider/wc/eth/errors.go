@@ -0,0 +1,10 @@
+package eth
+
+import "github.com/libs4go/errors"
+
+const errVendor = "eth"
+
+// errors
+var (
+	ErrNotSupported = errors.New("method not supported by Handler", errors.WithCode(-1), errors.WithVendor(errVendor))
+)
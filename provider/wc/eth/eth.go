@@ -0,0 +1,292 @@
+// Package eth dispatch ethereum wallet json rpc requests to typed Handler callbacks
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go/rpc"
+)
+
+// EIP-1193 provider error code
+const (
+	CodeUserRejected      int64 = 4001
+	CodeUnauthorized      int64 = 4100
+	CodeUnsupportedMethod int64 = 4200
+	CodeUnrecognizedChain int64 = 4902
+)
+
+// UserRejected create user rejected request error, handler return it when user deny the request
+func UserRejected(msg string) *rpc.Error {
+	return &rpc.Error{Code: CodeUserRejected, Message: msg}
+}
+
+func invalidParams(format string, args ...interface{}) error {
+	return &rpc.Error{Code: rpc.CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler wallet side ethereum method handler, callback returns *rpc.Error to reply a specific json rpc error,
+// other errors are replied as internal error
+type Handler interface {
+	// SendTransaction sign and broadcast tx, returns tx hash
+	SendTransaction(tx *Transaction) (string, error)
+	// SignTransaction sign tx, returns raw signed tx
+	SignTransaction(tx *Transaction) (string, error)
+	// Sign eth_sign, returns signature
+	Sign(address string, data []byte) (string, error)
+	// PersonalSign personal_sign, returns signature
+	PersonalSign(address string, message []byte) (string, error)
+	// SignTypedData eth_signTypedData_v3/eth_signTypedData_v4, returns signature
+	SignTypedData(address string, typedData *TypedData, version TypedDataVersion) (string, error)
+	// SwitchChain wallet_switchEthereumChain
+	SwitchChain(chainID int64) error
+	// AddChain wallet_addEthereumChain
+	AddChain(chain *Chain) error
+}
+
+type dispatcher struct {
+	slf4go.Logger
+	handler Handler
+}
+
+// RequestHandler create rpc request handler which decode, validate params and dispatch to handler,
+// methods not supported by Handler are passed to next, or replied with method not found if next is nil
+func RequestHandler(handler Handler, next rpc.Handler) rpc.Handler {
+	dispatcher := &dispatcher{
+		Logger:  slf4go.Get("eth-handler"),
+		handler: handler,
+	}
+
+	return func(session *rpc.Session, request *rpc.Request) {
+		result, err := dispatcher.dispatch(request)
+
+		if errors.Is(err, ErrNotSupported) {
+			if next != nil {
+				next(session, request)
+				return
+			}
+
+			err = &rpc.Error{Code: rpc.CodeMethodNotFound, Message: "method " + request.Method + " not found"}
+		}
+
+		if err != nil {
+			dispatcher.reply(session, request, err)
+			return
+		}
+
+		if err := session.ReplyRequest(request, result); err != nil {
+			dispatcher.E("reply {@method} error {@err}", request.Method, err)
+		}
+	}
+}
+
+func (dispatcher *dispatcher) reply(session *rpc.Session, request *rpc.Request, err error) {
+	var rpcErr *rpc.Error
+
+	if !errors.As(err, &rpcErr) {
+		dispatcher.E("handle {@method} error {@err}", request.Method, err)
+		rpcErr = &rpc.Error{Code: rpc.CodeInternalError, Message: "internal error"}
+	}
+
+	if err := session.ReplyRequestError(request, rpcErr.Code, rpcErr.Message); err != nil {
+		dispatcher.E("reply {@method} error {@err}", request.Method, err)
+	}
+}
+
+type method func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error)
+
+var methods = map[string]method{
+	"eth_sendTransaction": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.transaction(params, dispatcher.handler.SendTransaction)
+	},
+	"eth_signTransaction": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.transaction(params, dispatcher.handler.SignTransaction)
+	},
+	"eth_sign": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.sign(params)
+	},
+	"personal_sign": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.personalSign(params)
+	},
+	"eth_signTypedData_v3": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.signTypedData(params, TypedDataV3)
+	},
+	"eth_signTypedData_v4": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return dispatcher.signTypedData(params, TypedDataV4)
+	},
+	"wallet_switchEthereumChain": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return nil, dispatcher.switchChain(params)
+	},
+	"wallet_addEthereumChain": func(dispatcher *dispatcher, params []json.RawMessage) (interface{}, error) {
+		return nil, dispatcher.addChain(params)
+	},
+}
+
+func (dispatcher *dispatcher) dispatch(request *rpc.Request) (interface{}, error) {
+	f, ok := methods[request.Method]
+
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	var params []json.RawMessage
+
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return nil, invalidParams("%s expect params array", request.Method)
+	}
+
+	return f(dispatcher, params)
+}
+
+func expectParams(params []json.RawMessage, n int) error {
+	if len(params) < n {
+		return invalidParams("expect %d params got %d", n, len(params))
+	}
+
+	return nil
+}
+
+func stringParam(raw json.RawMessage, name string) (string, error) {
+	var value string
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", invalidParams("%s expect string", name)
+	}
+
+	return value, nil
+}
+
+func (dispatcher *dispatcher) transaction(params []json.RawMessage, f func(*Transaction) (string, error)) (interface{}, error) {
+	if err := expectParams(params, 1); err != nil {
+		return nil, err
+	}
+
+	tx, err := parseTransaction(params[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f(tx)
+}
+
+func (dispatcher *dispatcher) sign(params []json.RawMessage) (interface{}, error) {
+	if err := expectParams(params, 2); err != nil {
+		return nil, err
+	}
+
+	address, err := stringParam(params[0], "address")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if address, err = parseAddress("address", address); err != nil {
+		return nil, err
+	}
+
+	data, err := stringParam(params[1], "data")
+
+	if err != nil {
+		return nil, err
+	}
+
+	buff, err := parseData("data", data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dispatcher.handler.Sign(address, buff)
+}
+
+func (dispatcher *dispatcher) personalSign(params []json.RawMessage) (interface{}, error) {
+	if err := expectParams(params, 2); err != nil {
+		return nil, err
+	}
+
+	message, err := stringParam(params[0], "message")
+
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := stringParam(params[1], "address")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// some dapps send params in eth_sign order
+	if addressRegexp.MatchString(message) && !addressRegexp.MatchString(address) {
+		message, address = address, message
+	}
+
+	if address, err = parseAddress("address", address); err != nil {
+		return nil, err
+	}
+
+	return dispatcher.handler.PersonalSign(address, parseMessage(message))
+}
+
+func (dispatcher *dispatcher) signTypedData(params []json.RawMessage, version TypedDataVersion) (interface{}, error) {
+	if err := expectParams(params, 2); err != nil {
+		return nil, err
+	}
+
+	address, err := stringParam(params[0], "address")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if address, err = parseAddress("address", address); err != nil {
+		return nil, err
+	}
+
+	typedData, err := parseTypedData(params[1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dispatcher.handler.SignTypedData(address, typedData, version)
+}
+
+func (dispatcher *dispatcher) switchChain(params []json.RawMessage) error {
+	if err := expectParams(params, 1); err != nil {
+		return err
+	}
+
+	var param struct {
+		ChainID string `json:"chainId"`
+	}
+
+	if err := json.Unmarshal(params[0], &param); err != nil {
+		return invalidParams("expect chain object")
+	}
+
+	chainID, err := parseChainID(param.ChainID)
+
+	if err != nil {
+		return err
+	}
+
+	return dispatcher.handler.SwitchChain(chainID)
+}
+
+func (dispatcher *dispatcher) addChain(params []json.RawMessage) error {
+	if err := expectParams(params, 1); err != nil {
+		return err
+	}
+
+	chain, err := parseChain(params[0])
+
+	if err != nil {
+		return err
+	}
+
+	return dispatcher.handler.AddChain(chain)
+}
@@ -0,0 +1,210 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+const address = "0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549"
+
+type pipe struct {
+	in  chan []byte
+	out chan []byte
+}
+
+func (p *pipe) Read() ([]byte, error) {
+	return <-p.in, nil
+}
+
+func (p *pipe) Write(buff []byte) error {
+	p.out <- buff
+	return nil
+}
+
+type plainTunnel struct {
+}
+
+func (tunnel *plainTunnel) Send(msg []byte, transport tun4go.Transport) error {
+	return transport.Write(msg)
+}
+
+func (tunnel *plainTunnel) Recv(transport tun4go.Transport) ([]byte, error) {
+	return transport.Read()
+}
+
+func (tunnel *plainTunnel) Disconnect(transport tun4go.Transport) error { return nil }
+func (tunnel *plainTunnel) Connect(transport tun4go.Transport) error    { return nil }
+func (tunnel *plainTunnel) Context() ([]byte, error)                    { return nil, nil }
+
+type mockHandler struct {
+	tx        *Transaction
+	message   []byte
+	typedData *TypedData
+	version   TypedDataVersion
+	chainID   int64
+	chain     *Chain
+}
+
+func (handler *mockHandler) SendTransaction(tx *Transaction) (string, error) {
+	handler.tx = tx
+	return "0xhash", nil
+}
+
+func (handler *mockHandler) SignTransaction(tx *Transaction) (string, error) {
+	return "", UserRejected("user rejected")
+}
+
+func (handler *mockHandler) Sign(address string, data []byte) (string, error) {
+	handler.message = data
+	return "0xsig", nil
+}
+
+func (handler *mockHandler) PersonalSign(address string, message []byte) (string, error) {
+	handler.message = message
+	return "0xsig", nil
+}
+
+func (handler *mockHandler) SignTypedData(address string, typedData *TypedData, version TypedDataVersion) (string, error) {
+	handler.typedData = typedData
+	handler.version = version
+	return "0xsig", nil
+}
+
+func (handler *mockHandler) SwitchChain(chainID int64) error {
+	handler.chainID = chainID
+	return nil
+}
+
+func (handler *mockHandler) AddChain(chain *Chain) error {
+	handler.chain = chain
+	return errors.New("disk full")
+}
+
+func newSession(handler Handler) *rpc.Session {
+	a := make(chan []byte, 16)
+	b := make(chan []byte, 16)
+
+	wallet := rpc.New(&plainTunnel{}, &pipe{in: a, out: b}, rpc.WithRequestHandler(RequestHandler(handler, nil)))
+
+	go wallet.Run()
+
+	dapp := rpc.New(&plainTunnel{}, &pipe{in: b, out: a})
+
+	go dapp.Run()
+
+	return dapp
+}
+
+func requireCode(t *testing.T, err error, code int64) {
+	var rpcErr *rpc.Error
+
+	require.True(t, errors.As(err, &rpcErr), "expect rpc error got %v", err)
+	require.Equal(t, code, rpcErr.Code)
+}
+
+func TestTransaction(t *testing.T) {
+	handler := &mockHandler{}
+
+	dapp := newSession(handler)
+
+	var hash string
+
+	err := dapp.Call("eth_sendTransaction", []interface{}{map[string]string{
+		"from":     address,
+		"to":       address,
+		"gasLimit": "0x5208",
+		"value":    "0xde0b6b3a7640000",
+		"data":     "0x",
+	}}, &hash)
+
+	require.NoError(t, err)
+	require.Equal(t, "0xhash", hash)
+	require.Equal(t, big.NewInt(21000), handler.tx.Gas)
+	require.Equal(t, "1000000000000000000", handler.tx.Value.String())
+
+	err = dapp.Call("eth_sendTransaction", []interface{}{map[string]string{"from": "0x1234", "to": address}}, &hash)
+
+	requireCode(t, err, rpc.CodeInvalidParams)
+
+	err = dapp.Call("eth_sendTransaction", []interface{}{map[string]string{"from": address, "to": address, "gas": "21000"}}, &hash)
+
+	requireCode(t, err, rpc.CodeInvalidParams)
+
+	err = dapp.Call("eth_signTransaction", []interface{}{map[string]string{"from": address, "to": address}}, &hash)
+
+	requireCode(t, err, CodeUserRejected)
+}
+
+func TestSign(t *testing.T) {
+	handler := &mockHandler{}
+
+	dapp := newSession(handler)
+
+	var sig string
+
+	require.NoError(t, dapp.Call("eth_sign", []string{address, "0x68656c6c6f"}, &sig))
+	require.Equal(t, "hello", string(handler.message))
+
+	require.NoError(t, dapp.Call("personal_sign", []string{"0x68656c6c6f", address}, &sig))
+	require.Equal(t, "hello", string(handler.message))
+
+	require.NoError(t, dapp.Call("personal_sign", []string{address, "world"}, &sig))
+	require.Equal(t, "world", string(handler.message))
+
+	requireCode(t, dapp.Call("eth_sign", []string{address, "hello"}, &sig), rpc.CodeInvalidParams)
+}
+
+func TestSignTypedData(t *testing.T) {
+	handler := &mockHandler{}
+
+	dapp := newSession(handler)
+
+	typedData := `{
+		"types": {
+			"EIP712Domain": [{"name": "name", "type": "string"}],
+			"Mail": [{"name": "contents", "type": "string"}]
+		},
+		"primaryType": "Mail",
+		"domain": {"name": "test"},
+		"message": {"contents": "hello"}
+	}`
+
+	var sig string
+
+	require.NoError(t, dapp.Call("eth_signTypedData_v4", []string{address, typedData}, &sig))
+	require.Equal(t, TypedDataV4, handler.version)
+	require.Equal(t, "hello", handler.typedData.Message["contents"])
+
+	err := dapp.Call("eth_signTypedData_v3", []interface{}{address, map[string]interface{}{"primaryType": "Mail"}}, &sig)
+
+	requireCode(t, err, rpc.CodeInvalidParams)
+}
+
+func TestChain(t *testing.T) {
+	handler := &mockHandler{}
+
+	dapp := newSession(handler)
+
+	require.NoError(t, dapp.Call("wallet_switchEthereumChain", []interface{}{map[string]string{"chainId": "0x89"}}, nil))
+	require.Equal(t, int64(137), handler.chainID)
+
+	err := dapp.Call("wallet_addEthereumChain", []interface{}{map[string]interface{}{
+		"chainId":   "0x89",
+		"chainName": "Polygon",
+		"rpcUrls":   []string{"https://polygon-rpc.com"},
+	}}, nil)
+
+	requireCode(t, err, rpc.CodeInternalError)
+	require.Equal(t, "Polygon", handler.chain.ChainName)
+
+	err = dapp.Call("wallet_addEthereumChain", []interface{}{map[string]interface{}{"chainId": "0x89"}}, nil)
+
+	requireCode(t, err, rpc.CodeInvalidParams)
+
+	requireCode(t, dapp.Call("eth_chainId", nil, nil), rpc.CodeMethodNotFound)
+}
@@ -0,0 +1,265 @@
+package eth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var addressRegexp = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+// Transaction eth_sendTransaction/eth_signTransaction params
+type Transaction struct {
+	From                 string
+	To                   string   // empty for contract creation
+	Gas                  *big.Int // nil if not provided
+	GasPrice             *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	Value                *big.Int
+	Nonce                *big.Int
+	Data                 []byte
+}
+
+type transactionJSON struct {
+	From                 string `json:"from"`
+	To                   string `json:"to"`
+	Gas                  string `json:"gas"`
+	GasLimit             string `json:"gasLimit"`
+	GasPrice             string `json:"gasPrice"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	Value                string `json:"value"`
+	Nonce                string `json:"nonce"`
+	Data                 string `json:"data"`
+	Input                string `json:"input"`
+}
+
+// TypedDataField EIP-712 struct member
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData EIP-712 typed data
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// TypedDataVersion eth_signTypedData version
+type TypedDataVersion string
+
+// TypedDataVersion enum
+const (
+	TypedDataV3 TypedDataVersion = "v3"
+	TypedDataV4 TypedDataVersion = "v4"
+)
+
+// NativeCurrency wallet_addEthereumChain native currency
+type NativeCurrency struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// Chain wallet_addEthereumChain params
+type Chain struct {
+	ChainID           int64           `json:"-"`
+	ChainName         string          `json:"chainName"`
+	NativeCurrency    *NativeCurrency `json:"nativeCurrency,omitempty"`
+	RPCURLs           []string        `json:"rpcUrls"`
+	BlockExplorerURLs []string        `json:"blockExplorerUrls,omitempty"`
+	IconURLs          []string        `json:"iconUrls,omitempty"`
+}
+
+func parseAddress(name string, value string) (string, error) {
+	if !addressRegexp.MatchString(value) {
+		return "", invalidParams("%s %s is not a valid address", name, value)
+	}
+
+	return value, nil
+}
+
+func parseQuantity(name string, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(value, "0x") || len(value) == 2 {
+		return nil, invalidParams("%s %s is not a hex quantity", name, value)
+	}
+
+	n, ok := new(big.Int).SetString(value[2:], 16)
+
+	if !ok {
+		return nil, invalidParams("%s %s is not a hex quantity", name, value)
+	}
+
+	return n, nil
+}
+
+func parseData(name string, value string) ([]byte, error) {
+	if !strings.HasPrefix(value, "0x") {
+		return nil, invalidParams("%s %s is not hex data", name, value)
+	}
+
+	buff, err := hex.DecodeString(value[2:])
+
+	if err != nil {
+		return nil, invalidParams("%s %s is not hex data", name, value)
+	}
+
+	return buff, nil
+}
+
+func parseChainID(value string) (int64, error) {
+	n, err := parseQuantity("chainId", value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if n == nil || !n.IsInt64() || n.Sign() <= 0 {
+		return 0, invalidParams("chainId %s out of range", value)
+	}
+
+	return n.Int64(), nil
+}
+
+func parseTransaction(raw json.RawMessage) (*Transaction, error) {
+	var tj *transactionJSON
+
+	if err := json.Unmarshal(raw, &tj); err != nil || tj == nil {
+		return nil, invalidParams("expect transaction object")
+	}
+
+	tx := &Transaction{}
+
+	var err error
+
+	if tx.From, err = parseAddress("from", tj.From); err != nil {
+		return nil, err
+	}
+
+	if tj.To != "" {
+		if tx.To, err = parseAddress("to", tj.To); err != nil {
+			return nil, err
+		}
+	}
+
+	if tj.Gas == "" {
+		tj.Gas = tj.GasLimit
+	}
+
+	quantities := []struct {
+		name  string
+		value string
+		field **big.Int
+	}{
+		{"gas", tj.Gas, &tx.Gas},
+		{"gasPrice", tj.GasPrice, &tx.GasPrice},
+		{"maxFeePerGas", tj.MaxFeePerGas, &tx.MaxFeePerGas},
+		{"maxPriorityFeePerGas", tj.MaxPriorityFeePerGas, &tx.MaxPriorityFeePerGas},
+		{"value", tj.Value, &tx.Value},
+		{"nonce", tj.Nonce, &tx.Nonce},
+	}
+
+	for _, q := range quantities {
+		if *q.field, err = parseQuantity(q.name, q.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if tj.Data == "" {
+		tj.Data = tj.Input
+	}
+
+	if tj.Data != "" {
+		if tx.Data, err = parseData("data", tj.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if tx.To == "" && len(tx.Data) == 0 {
+		return nil, invalidParams("contract creation transaction expect data")
+	}
+
+	return tx, nil
+}
+
+// parseMessage decode personal_sign message, which is hex data or plain utf8 text
+func parseMessage(value string) []byte {
+	if buff, err := parseData("message", value); err == nil {
+		return buff
+	}
+
+	return []byte(value)
+}
+
+func parseTypedData(raw json.RawMessage) (*TypedData, error) {
+	// typed data may be passed as json string or json object
+	var str string
+
+	if err := json.Unmarshal(raw, &str); err == nil {
+		raw = json.RawMessage(str)
+	}
+
+	var typedData *TypedData
+
+	if err := json.Unmarshal(raw, &typedData); err != nil || typedData == nil {
+		return nil, invalidParams("expect typed data object")
+	}
+
+	if _, ok := typedData.Types["EIP712Domain"]; !ok {
+		return nil, invalidParams("typed data expect EIP712Domain type")
+	}
+
+	if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		return nil, invalidParams("typed data primaryType %s not defined", typedData.PrimaryType)
+	}
+
+	if typedData.Message == nil {
+		return nil, invalidParams("typed data expect message")
+	}
+
+	return typedData, nil
+}
+
+func parseChain(raw json.RawMessage) (*Chain, error) {
+	var chain struct {
+		Chain
+		ChainID string `json:"chainId"`
+	}
+
+	if err := json.Unmarshal(raw, &chain); err != nil {
+		return nil, invalidParams("expect chain object")
+	}
+
+	chainID, err := parseChainID(chain.ChainID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if chain.ChainName == "" {
+		return nil, invalidParams("chainName is required")
+	}
+
+	if len(chain.RPCURLs) == 0 {
+		return nil, invalidParams("rpcUrls is required")
+	}
+
+	if chain.NativeCurrency != nil && (chain.NativeCurrency.Decimals != 18 || chain.NativeCurrency.Symbol == "") {
+		return nil, invalidParams("invalid nativeCurrency %s decimals %d",
+			chain.NativeCurrency.Symbol, chain.NativeCurrency.Decimals)
+	}
+
+	chain.Chain.ChainID = chainID
+
+	return &chain.Chain, nil
+}
@@ -0,0 +1,254 @@
+package wc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/internal/jsonrpc"
+)
+
+// PeerLost status of session whose peer is silent longer than the keepalive timeout,
+// Resume the session to continue after the peer comes back
+const PeerLost Status = "peer-lost"
+
+// DefaultProbeMethod default keepalive probe json rpc method. It is a tun4go extension, not part of
+// wallet connect v1: only a tun4go wc tunnel with Keepalive set answers it, other v1 peers never do
+const DefaultProbeMethod = "wc_sessionPing"
+
+// Keepalive application level liveness probe of connected session.
+//
+// The websocket ping/pong of ws transport only detects the dead bridge connection, a peer closing
+// its page without wc_sessionUpdate is detected by probing it: after the peer is silent for Interval
+// a probe request is sent, any msg from peer include error response proves it alive,
+// Recv returns ErrPeerLost and the tunnel status turns to PeerLost if peer is silent for Timeout.
+// Probing other v1 peers needs a Method the peer answers, e.g. eth_chainId to probe a wallet.
+// Keepalive requires a tun4go.ContextTransport to interrupt the blocking read, Connect, Resume and Recv
+// return ErrParams on other transports, wrap them with tun4go.WithContext
+type Keepalive struct {
+	Interval time.Duration // probe the peer after silent for interval
+	Timeout  time.Duration // peer is lost after silent for timeout, must be greater than Interval
+	Method   string        // probe method, default is DefaultProbeMethod which only a tun4go peer answers
+}
+
+// Validate check keepalive options
+func (keepalive *Keepalive) Validate() error {
+	if keepalive.Interval <= 0 {
+		return errors.Wrap(ErrParams, "Keepalive.Interval %s must be positive", keepalive.Interval)
+	}
+
+	if keepalive.Timeout <= keepalive.Interval {
+		return errors.Wrap(ErrParams, "Keepalive.Timeout %s must be greater than Interval %s", keepalive.Timeout, keepalive.Interval)
+	}
+
+	return nil
+}
+
+func (keepalive *Keepalive) method() string {
+	if keepalive.Method == "" {
+		return DefaultProbeMethod
+	}
+
+	return keepalive.Method
+}
+
+func (tunnel *wcTunnel) SetKeepalive(keepalive *Keepalive) error {
+	if keepalive != nil {
+		if err := keepalive.Validate(); err != nil {
+			return err
+		}
+	}
+
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.keepalive = keepalive
+	tunnel.seen = time.Now()
+
+	return nil
+}
+
+// checkKeepalive check transport is able to honour keepalive
+func (tunnel *wcTunnel) checkKeepalive(transport tun4go.Transport) error {
+	tunnel.mutex.RLock()
+	keepalive := tunnel.keepalive
+	tunnel.mutex.RUnlock()
+
+	if keepalive == nil {
+		return nil
+	}
+
+	_, err := keepaliveTransport(transport)
+
+	return err
+}
+
+func keepaliveTransport(transport tun4go.Transport) (tun4go.ContextTransport, error) {
+	ct, ok := transport.(tun4go.ContextTransport)
+
+	if !ok {
+		return nil, errors.Wrap(ErrParams, "keepalive requires tun4go.ContextTransport, wrap transport with tun4go.WithContext")
+	}
+
+	return ct, nil
+}
+
+// alive mark peer alive, any msg from peer proves it
+func (tunnel *wcTunnel) alive() {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.seen = time.Now()
+	tunnel.probing = false
+}
+
+// readAlive read transport, probe the silent peer and report it lost if keepalive is set
+func (tunnel *wcTunnel) readAlive(ctx context.Context, transport tun4go.Transport) ([]byte, error) {
+	tunnel.mutex.RLock()
+	keepalive := tunnel.keepalive
+	tunnel.mutex.RUnlock()
+
+	if keepalive == nil {
+		return tun4go.ReadContext(ctx, transport)
+	}
+
+	ct, err := keepaliveTransport(transport)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tunnel.mutex.Lock()
+
+		if tunnel.seen.IsZero() {
+			tunnel.seen = time.Now()
+		}
+
+		idle := time.Since(tunnel.seen)
+		probing := tunnel.probing
+		peer := tunnel.Peer
+
+		if idle >= keepalive.Timeout {
+			tunnel.probes = nil
+
+			if tunnel.State == Connected {
+				tunnel.State = PeerLost
+			}
+
+			tunnel.mutex.Unlock()
+
+			return nil, errors.Wrap(ErrPeerLost, "peer %s silent for %s", peer, idle)
+		}
+
+		tunnel.mutex.Unlock()
+
+		wait := keepalive.Timeout - idle
+
+		if !probing {
+			if idle >= keepalive.Interval {
+				if err := tunnel.sendProbe(ctx, keepalive.method(), transport); err != nil {
+					return nil, err
+				}
+			} else if keepalive.Interval-idle < wait {
+				wait = keepalive.Interval - idle
+			}
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, wait)
+
+		data, err := ct.ReadContext(readCtx)
+
+		// check before cancel, which sets readCtx.Err()
+		expired := readCtx.Err() != nil
+
+		cancel()
+
+		if err == nil {
+			tunnel.alive()
+			return data, nil
+		}
+
+		// only the keepalive deadline continues the loop
+		if ctx.Err() != nil || !expired {
+			return nil, err
+		}
+	}
+}
+
+func (tunnel *wcTunnel) sendProbe(ctx context.Context, method string, transport tun4go.Transport) error {
+	rpc := &jsonRPCRequest{
+		ID:      jsonrpc.NewID(),
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  []interface{}{},
+	}
+
+	buff, err := json.Marshal(rpc)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal keepalive probe error")
+	}
+
+	tunnel.mutex.Lock()
+
+	if tunnel.probes == nil {
+		tunnel.probes = make(map[int64]bool)
+	}
+
+	tunnel.probes[rpc.ID] = true
+	tunnel.probing = true
+
+	tunnel.mutex.Unlock()
+
+	if err := tunnel.doSend(ctx, buff, transport); err != nil {
+		return errors.Wrap(err, "send keepalive probe error")
+	}
+
+	return nil
+}
+
+// answerProbe answer DefaultProbeMethod request of peer if keepalive is set and swallow response of self probe,
+// returns true if msg is handled and the response to send back if any
+func (tunnel *wcTunnel) answerProbe(buff []byte) (bool, []byte, error) {
+	var msg struct {
+		ID     int64  `json:"id"`
+		Method string `json:"method"`
+	}
+
+	if err := json.Unmarshal(buff, &msg); err != nil {
+		return false, nil, nil
+	}
+
+	tunnel.mutex.Lock()
+
+	if msg.Method == "" {
+		probe := tunnel.probes[msg.ID]
+		delete(tunnel.probes, msg.ID)
+		tunnel.mutex.Unlock()
+		return probe, nil, nil
+	}
+
+	// custom probe methods are requests the peer application answers
+	keepalive := tunnel.keepalive != nil && tunnel.keepalive.method() == DefaultProbeMethod
+
+	tunnel.mutex.Unlock()
+
+	if !keepalive || msg.Method != DefaultProbeMethod {
+		return false, nil, nil
+	}
+
+	rsp, err := json.Marshal(&jsonRPCResponse{
+		ID:      msg.ID,
+		JSONRPC: "2.0",
+		Result:  true,
+	})
+
+	if err != nil {
+		return false, nil, errors.Wrap(err, "marshal keepalive probe response error")
+	}
+
+	return true, rsp, nil
+}
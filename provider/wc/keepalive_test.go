@@ -0,0 +1,164 @@
+package wc
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepalive(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	wallet := p.wallet.(Tunnel)
+
+	require.NoError(t, wallet.SetKeepalive(&Keepalive{Interval: 50 * time.Millisecond, Timeout: 200 * time.Millisecond}))
+
+	// dapp answers DefaultProbeMethod only with keepalive set
+	require.NoError(t, p.dapp.(Tunnel).SetKeepalive(&Keepalive{Interval: time.Minute, Timeout: time.Hour}))
+
+	walletTransport := tun4go.WithContext(p.walletTransport)
+	dappTransport := tun4go.WithContext(p.dappTransport)
+
+	dappDone := make(chan error, 1)
+
+	go func() {
+		// dapp answers wallet probes in Recv
+		_, err := p.dapp.Recv(dappTransport)
+		dappDone <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err := wallet.RecvContext(ctx, walletTransport)
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, Connected, wallet.Status())
+
+	// dapp closes without wc_sessionUpdate
+	dappTransport.(io.Closer).Close()
+
+	<-dappDone
+
+	_, err = wallet.Recv(walletTransport)
+
+	require.True(t, errors.Is(err, ErrPeerLost))
+	require.Equal(t, PeerLost, wallet.Status())
+
+	_, err = wallet.Recv(walletTransport)
+
+	require.True(t, errors.Is(err, ErrStatus))
+
+	require.NoError(t, wallet.Resume(walletTransport))
+	require.Equal(t, Connected, wallet.Status())
+}
+
+func TestKeepaliveServe(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	wallet := p.wallet.(Tunnel)
+
+	require.NoError(t, wallet.SetKeepalive(&Keepalive{Interval: 50 * time.Millisecond, Timeout: 200 * time.Millisecond}))
+
+	// dapp goes away without wc_sessionUpdate
+	p.dappTransport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := 0
+
+	// Serve stops on peer lost even if OnError keeps serving
+	err := tun4go.Serve(ctx, wallet, p.walletTransport, &tun4go.Handlers{
+		OnError: func(err error) error {
+			errs++
+			return nil
+		},
+	})
+
+	require.True(t, errors.Is(err, ErrPeerLost))
+	require.Equal(t, 0, errs)
+	require.Equal(t, PeerLost, wallet.Status())
+
+	// connect does not pretend to reconnect a lost session
+	require.True(t, errors.Is(wallet.Connect(p.walletTransport), ErrStatus))
+
+	require.NoError(t, wallet.Resume(p.walletTransport))
+	require.Equal(t, Connected, wallet.Status())
+}
+
+func TestProbeWithoutKeepalive(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	probe := `{"id":1,"jsonrpc":"2.0","method":"wc_sessionPing","params":[]}`
+
+	require.NoError(t, p.dapp.Send([]byte(probe), p.dappTransport))
+
+	// probe of peer is passed to application if keepalive is not set
+	buff, err := p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.JSONEq(t, probe, string(buff))
+}
+
+// plainTransport hide ContextTransport of underlying transport
+type plainTransport struct {
+	tun4go.Transport
+}
+
+func TestKeepaliveTransport(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	wallet := p.wallet.(Tunnel)
+
+	require.NoError(t, wallet.SetKeepalive(&Keepalive{Interval: time.Second, Timeout: 2 * time.Second}))
+
+	_, err := wallet.Recv(&plainTransport{p.walletTransport})
+
+	require.True(t, errors.Is(err, ErrParams))
+
+	require.True(t, errors.Is(wallet.Resume(&plainTransport{p.walletTransport}), ErrParams))
+
+	// ws transport is a tun4go.ContextTransport
+	require.NoError(t, wallet.Resume(p.walletTransport))
+}
+
+func TestKeepaliveOptions(t *testing.T) {
+	require.Error(t, (&Keepalive{}).Validate())
+	require.Error(t, (&Keepalive{Interval: time.Second, Timeout: time.Second}).Validate())
+	require.NoError(t, (&Keepalive{Interval: time.Second, Timeout: 3 * time.Second}).Validate())
+
+	options, err := optionsFromParams(tun4go.Params{
+		"keepaliveInterval": "10s",
+		"keepaliveTimeout":  "30s",
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, &Keepalive{Interval: 10 * time.Second, Timeout: 30 * time.Second}, options.Keepalive)
+
+	_, err = optionsFromParams(tun4go.Params{"keepaliveTimeout": "30s"})
+
+	require.True(t, errors.Is(err, ErrParams))
+}
@@ -0,0 +1,319 @@
+package wc
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+)
+
+type managerOptions struct {
+	encoding   string
+	queueLimit int
+}
+
+// ManagerOption session manager option
+type ManagerOption func(options *managerOptions)
+
+// WithManagerEncoding set envelope encoding name of sessions, default is json
+func WithManagerEncoding(encoding string) ManagerOption {
+	return func(options *managerOptions) {
+		options.encoding = encoding
+	}
+}
+
+// WithManagerQueueLimit set max number of frames queued per session and not yet read, default is 1024,
+// the session fails with ErrQueueFull when a frame arrives at a full queue, other sessions are not affected
+func WithManagerQueueLimit(limit int) ManagerOption {
+	return func(options *managerOptions) {
+		options.queueLimit = limit
+	}
+}
+
+// Manager share one bridge transport between many wc sessions, incoming frames are
+// dispatched to the session which subscribed the frame topic, one topic belongs to one session
+type Manager struct {
+	logger     slf4go.Logger
+	mutex      sync.Mutex
+	transport  tun4go.Transport
+	encoding   tun4go.Encoding
+	queueLimit int
+	writeLock  sync.Mutex
+	routes     map[string]*SessionTransport
+	sessions   map[*SessionTransport]bool
+	err        error
+}
+
+// NewManager create session manager over bridge transport
+func NewManager(transport tun4go.Transport, opts ...ManagerOption) (*Manager, error) {
+	options := &managerOptions{
+		encoding:   tun4go.JSON,
+		queueLimit: 1024,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	encoding, err := tun4go.GetEncoding(options.encoding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		logger:     slf4go.Get("wc-manager"),
+		transport:  transport,
+		encoding:   encoding,
+		queueLimit: options.queueLimit,
+		routes:     make(map[string]*SessionTransport),
+		sessions:   make(map[*SessionTransport]bool),
+	}, nil
+}
+
+// Open create virtual transport for one session, pass it to the tunnel functions instead of the bridge transport
+func (manager *Manager) Open() *SessionTransport {
+	session := &SessionTransport{
+		manager: manager,
+		signal:  make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.sessions[session] = true
+
+	if manager.err != nil {
+		session.fail(manager.err)
+	}
+
+	return session
+}
+
+// Topics list subscribed topics of all sessions
+func (manager *Manager) Topics() []string {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	var topics []string
+
+	for topic := range manager.routes {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	return topics
+}
+
+// Run read bridge transport and dispatch frames until read error or ctx done,
+// the error is reported to all sessions. Wrap bridge transport with tun4go.WithContext
+// if it is not a ContextTransport, otherwise ctx done is checked only between frames
+func (manager *Manager) Run(ctx context.Context) error {
+	for {
+		var buff []byte
+		var err error
+
+		if ct, ok := manager.transport.(tun4go.ContextTransport); ok {
+			buff, err = ct.ReadContext(ctx)
+		} else if err = ctx.Err(); err == nil {
+			buff, err = manager.transport.Read()
+		}
+
+		if err != nil {
+			manager.close(err)
+			return err
+		}
+
+		manager.dispatch(buff)
+	}
+}
+
+func (manager *Manager) dispatch(buff []byte) {
+	var msg *socketMessage
+
+	if err := manager.encoding.Unmarshal(buff, &msg); err != nil || msg == nil {
+		manager.logger.W("skip invalid frame {@frame}", string(buff))
+		return
+	}
+
+	manager.mutex.Lock()
+	session, ok := manager.routes[msg.Topic]
+	manager.mutex.Unlock()
+
+	if !ok {
+		manager.logger.W("skip frame of unknown topic {@topic}", msg.Topic)
+		return
+	}
+
+	if err := session.push(buff, manager.queueLimit); err != nil {
+		manager.logger.E("fail session of topic {@topic}, {@err}", msg.Topic, err)
+	}
+}
+
+func (manager *Manager) close(err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.err = err
+
+	for session := range manager.sessions {
+		session.fail(err)
+	}
+}
+
+func (manager *Manager) write(ctx context.Context, session *SessionTransport, buff []byte) error {
+	var msg *socketMessage
+
+	if err := manager.encoding.Unmarshal(buff, &msg); err == nil && msg != nil && msg.Type == "sub" {
+		manager.mutex.Lock()
+
+		if owner, ok := manager.routes[msg.Topic]; ok && owner != session {
+			manager.mutex.Unlock()
+			return errors.Wrap(ErrTopicTaken, "subscribe topic %s", msg.Topic)
+		}
+
+		manager.routes[msg.Topic] = session
+		manager.mutex.Unlock()
+	}
+
+	manager.writeLock.Lock()
+	defer manager.writeLock.Unlock()
+
+	if ct, ok := manager.transport.(tun4go.ContextTransport); ok {
+		return ct.WriteContext(ctx, buff)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return manager.transport.Write(buff)
+}
+
+func (manager *Manager) remove(session *SessionTransport) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	delete(manager.sessions, session)
+
+	for topic, s := range manager.routes {
+		if s == session {
+			delete(manager.routes, topic)
+		}
+	}
+}
+
+// SessionTransport virtual transport of one session managed by Manager
+type SessionTransport struct {
+	mutex     sync.Mutex
+	manager   *Manager
+	queue     [][]byte
+	err       error
+	signal    chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (session *SessionTransport) push(buff []byte, limit int) error {
+	session.mutex.Lock()
+
+	if session.err != nil {
+		session.mutex.Unlock()
+		return nil
+	}
+
+	var err error
+
+	if len(session.queue) >= limit {
+		err = errors.Wrap(ErrQueueFull, "%d frames queued", len(session.queue))
+		session.err = err
+	} else {
+		session.queue = append(session.queue, buff)
+	}
+
+	session.mutex.Unlock()
+
+	select {
+	case session.signal <- struct{}{}:
+	default:
+	}
+
+	return err
+}
+
+func (session *SessionTransport) fail(err error) {
+	session.mutex.Lock()
+	session.err = err
+	session.mutex.Unlock()
+
+	select {
+	case session.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Read read next frame of session topics
+func (session *SessionTransport) Read() ([]byte, error) {
+	return session.ReadContext(context.Background())
+}
+
+// ReadContext context aware Read
+func (session *SessionTransport) ReadContext(ctx context.Context) ([]byte, error) {
+	for {
+		session.mutex.Lock()
+
+		if len(session.queue) != 0 {
+			buff := session.queue[0]
+			session.queue = session.queue[1:]
+			session.mutex.Unlock()
+			return buff, nil
+		}
+
+		err := session.err
+
+		session.mutex.Unlock()
+
+		if err != nil {
+			return nil, errors.Wrap(err, "read from bridge error")
+		}
+
+		select {
+		case <-session.signal:
+		case <-session.closed:
+			return nil, errors.Wrap(ErrClosed, "read from closed session transport")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Write write frame to the shared bridge transport
+func (session *SessionTransport) Write(buff []byte) error {
+	return session.WriteContext(context.Background(), buff)
+}
+
+// WriteContext context aware Write
+func (session *SessionTransport) WriteContext(ctx context.Context, buff []byte) error {
+	select {
+	case <-session.closed:
+		return errors.Wrap(ErrClosed, "write to closed session transport")
+	default:
+	}
+
+	return session.manager.write(ctx, session, buff)
+}
+
+// Close remove session and its topics from manager, the shared bridge transport is not closed
+func (session *SessionTransport) Close() error {
+	session.closeOnce.Do(func() {
+		close(session.closed)
+		session.manager.remove(session)
+	})
+
+	return nil
+}
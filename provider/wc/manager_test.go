@@ -0,0 +1,184 @@
+package wc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/transport/ws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	transport, err := ws.Dial(bridgeServer.URL())
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	manager, err := NewManager(tun4go.WithContext(transport))
+
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go manager.Run(ctx)
+
+	type session struct {
+		dapp          Tunnel
+		dappTransport *ws.Transport
+		wallet        Tunnel
+		transport     *SessionTransport
+	}
+
+	var sessions []*session
+
+	for i := 0; i < 2; i++ {
+		dapp, err := New(&Options{Role: Dapp, Bridge: bridgeServer.URL(), ClientInfo: &ClientInfo{Name: "dapp"}})
+
+		require.NoError(t, err)
+
+		dappTransport, err := ws.Dial(bridgeServer.URL())
+
+		require.NoError(t, err)
+
+		defer dappTransport.Close()
+
+		wallet, err := New(&Options{
+			URL:        dapp.HandshakeURL().String(),
+			Accounts:   []string{account},
+			ChainID:    1,
+			ClientInfo: &ClientInfo{Name: "wallet"},
+		})
+
+		require.NoError(t, err)
+
+		sessionTransport := manager.Open()
+
+		walletErr := make(chan error, 1)
+
+		go func() {
+			walletErr <- wallet.Connect(sessionTransport)
+		}()
+
+		require.NoError(t, dapp.Connect(dappTransport))
+		require.NoError(t, <-walletErr)
+
+		sessions = append(sessions, &session{
+			dapp:          dapp,
+			dappTransport: dappTransport,
+			wallet:        wallet,
+			transport:     sessionTransport,
+		})
+	}
+
+	// handshake and self topic of each session
+	require.Len(t, manager.Topics(), 4)
+
+	for i, s := range sessions {
+		msg := []byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`)
+
+		msg[6] = byte('0' + i)
+
+		require.NoError(t, s.dapp.Send(msg, s.dappTransport))
+	}
+
+	for i, s := range sessions {
+		buff, err := s.wallet.Recv(s.transport)
+
+		require.NoError(t, err)
+		require.Equal(t, byte('0'+i), buff[6])
+	}
+
+	require.NoError(t, sessions[0].transport.Close())
+
+	require.Len(t, manager.Topics(), 2)
+
+	_, err = sessions[0].wallet.Recv(sessions[0].transport)
+
+	require.True(t, errors.Is(err, ErrClosed))
+
+	cancel()
+
+	_, err = sessions[1].wallet.(tun4go.ContextTunnel).RecvContext(context.Background(), sessions[1].transport)
+
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestManagerTopicTaken(t *testing.T) {
+	transport, err := ws.Dial(bridgeServer.URL())
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	manager, err := NewManager(transport)
+
+	require.NoError(t, err)
+
+	first := manager.Open()
+	second := manager.Open()
+
+	sub := []byte(`{"topic":"manager-taken","type":"sub","payload":""}`)
+
+	require.NoError(t, first.Write(sub))
+	require.NoError(t, first.Write(sub))
+	require.True(t, errors.Is(second.Write(sub), ErrTopicTaken))
+
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Write(sub))
+}
+
+func TestManagerQueueLimit(t *testing.T) {
+	transport, err := ws.Dial(bridgeServer.URL())
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	manager, err := NewManager(tun4go.WithContext(transport), WithManagerQueueLimit(1))
+
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go manager.Run(ctx)
+
+	session := manager.Open()
+	other := manager.Open()
+
+	require.NoError(t, session.Write([]byte(`{"topic":"manager-full","type":"sub","payload":""}`)))
+	require.NoError(t, other.Write([]byte(`{"topic":"manager-other","type":"sub","payload":""}`)))
+
+	publisher, err := ws.Dial(bridgeServer.URL())
+
+	require.NoError(t, err)
+
+	defer publisher.Close()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, publisher.Write([]byte(`{"topic":"manager-full","type":"pub","payload":"hello"}`)))
+	}
+
+	require.NoError(t, publisher.Write([]byte(`{"topic":"manager-other","type":"pub","payload":"hello"}`)))
+
+	// the other session still gets frames once the full one failed
+	_, err = other.Read()
+
+	require.NoError(t, err)
+
+	_, err = session.Read()
+
+	require.NoError(t, err)
+
+	_, err = session.Read()
+
+	require.True(t, errors.Is(err, ErrQueueFull))
+}
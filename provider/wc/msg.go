@@ -8,20 +8,65 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 
 	"github.com/libs4go/errors"
 )
 
+// hexBytes bytes encoded as hex string in json, and as raw bytes in binary encodings
+type hexBytes []byte
+
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var str string
+
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	buff, err := hex.DecodeString(str)
+
+	if err != nil {
+		return errors.Wrap(err, "decode hex %s error", str)
+	}
+
+	*h = buff
+
+	return nil
+}
+
+// textBytes bytes encoded as string in json, and as raw bytes in binary encodings
+type textBytes []byte
+
+func (t textBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func (t *textBytes) UnmarshalJSON(data []byte) error {
+	var str string
+
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	*t = []byte(str)
+
+	return nil
+}
+
 type socketMessage struct {
-	Topic   string `json:"topic"`
-	Type    string `json:"type"`
-	Payload string `json:"payload"`
+	Topic   string    `json:"topic"`
+	Type    string    `json:"type"`
+	Payload textBytes `json:"payload"`
 }
 
 type encryptionPayload struct {
-	Data string `json:"data"`
-	Hmac string `json:"hmac"`
-	IV   string `json:"iv"`
+	Data hexBytes `json:"data"`
+	Hmac hexBytes `json:"hmac"`
+	IV   hexBytes `json:"iv"`
 }
 
 type jsonRPCRequest struct {
@@ -46,13 +91,13 @@ type jsonRPCError struct {
 
 type sessionRequest struct {
 	PeerID   string      `json:"peerId"`
-	PeerMeta *clientInfo `json:"peerMeta"`
+	PeerMeta *ClientInfo `json:"peerMeta"`
 	ChainID  *int64      `json:"chainId"`
 }
 
 type sessionResponse struct {
 	PeerID   string      `json:"peerId"`
-	PeerMeta *clientInfo `json:"peerMeta"`
+	PeerMeta *ClientInfo `json:"peerMeta"`
 	ChainID  int64       `json:"chainId"`
 	Approved bool        `json:"approved"`
 	Accounts []string    `json:"accounts"`
@@ -64,44 +109,54 @@ type sessionUpdate struct {
 	Accounts []string `json:"accounts"`
 }
 
-func (payload *encryptionPayload) decrypt(key []byte) ([]byte, error) {
+// readJSONRPCBatch split json rpc batch into raw elements, returns false if buff is not a batch
+func readJSONRPCBatch(buff []byte) ([]json.RawMessage, bool) {
+	buff = bytes.TrimSpace(buff)
 
-	data, err := hex.DecodeString(payload.Data)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "decode data %s error", payload.Data)
+	if len(buff) == 0 || buff[0] != '[' {
+		return nil, false
 	}
 
-	hmac, err := hex.DecodeString(payload.Hmac)
+	var elements []json.RawMessage
 
-	if err != nil {
-		return nil, errors.Wrap(err, "decode hmac %s error", payload.Hmac)
+	if err := json.Unmarshal(buff, &elements); err != nil || len(elements) == 0 {
+		return nil, false
 	}
 
-	iv, err := hex.DecodeString(payload.IV)
+	return elements, true
+}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "decode iv %s error", payload.IV)
+func (payload *encryptionPayload) decrypt(key []byte) ([]byte, error) {
+
+	if len(payload.IV) != aes.BlockSize || len(payload.Data) == 0 || len(payload.Data)%aes.BlockSize != 0 {
+		return nil, errors.Wrap(ErrFormat, "invalid encryption payload iv %d bytes data %d bytes", len(payload.IV), len(payload.Data))
 	}
 
-	expect := computeHmac(data, iv, key)
+	expect := computeHmac(payload.Data, payload.IV, key)
 
-	if !bytes.Equal(expect, hmac) {
-		return nil, errors.Wrap(ErrHMAC, "hmac expect %s got %s", hex.EncodeToString(expect), payload.Hmac)
+	if !hmac.Equal(expect, payload.Hmac) {
+		return nil, errors.Wrap(ErrHMAC, "hmac expect %s got %s", hex.EncodeToString(expect), hex.EncodeToString(payload.Hmac))
 	}
 
 	block, _ := aes.NewCipher(key)
 
-	decrypter := cipher.NewCBCDecrypter(block, iv[:])
+	decrypter := cipher.NewCBCDecrypter(block, payload.IV)
+
+	data := make([]byte, len(payload.Data))
 
-	decrypter.CryptBlocks(data, data)
+	decrypter.CryptBlocks(data, payload.Data)
 
-	return pkcs5Trimming(data), nil
+	return pkcs5Trimming(data)
 }
 
-func pkcs5Trimming(encrypt []byte) []byte {
-	padding := encrypt[len(encrypt)-1]
-	return encrypt[:len(encrypt)-int(padding)]
+func pkcs5Trimming(encrypt []byte) ([]byte, error) {
+	padding := int(encrypt[len(encrypt)-1])
+
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.Wrap(ErrFormat, "invalid pkcs5 padding %d", padding)
+	}
+
+	return encrypt[:len(encrypt)-padding], nil
 }
 
 func pkcs5Padding(ciphertext []byte, blockSize int, after int) []byte {
@@ -128,17 +183,15 @@ func encrypt(data []byte, key []byte) (*encryptionPayload, error) {
 
 	encrypter.CryptBlocks(cipherData, plainData)
 
-	mac := hex.EncodeToString(computeHmac(cipherData, iv[:], key))
-
 	return &encryptionPayload{
-		Data: hex.EncodeToString(cipherData),
-		Hmac: mac,
-		IV:   hex.EncodeToString(iv[:]),
+		Data: cipherData,
+		Hmac: computeHmac(cipherData, iv[:], key),
+		IV:   iv[:],
 	}, nil
 }
 
 func computeHmac(payload []byte, iv []byte, key []byte) []byte {
-	data := append(payload, iv...)
+	data := append(append([]byte{}, payload...), iv...)
 
 	mac := hmac.New(sha256.New, key)
 
@@ -0,0 +1,184 @@
+package wc
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+)
+
+// Options wc tunnel create options
+type Options struct {
+	Role       Role        // tunnel role, default is Wallet
+	URL        string      // wallet: handshake url provide by dapp
+	Bridge     string      // dapp: bridge url
+	Accounts   []string    // wallet: approved accounts, plain addresses or CAIP-10 account ids of one chain
+	ChainID    int64       // wallet: approved chain id, optional if Accounts are CAIP-10, dapp: optional requested chain id
+	ClientInfo *ClientInfo // self client metadata
+	PeerID     string      // optional self peer id override, default is random uuid
+	Encoding   string      // optional envelope encoding name, default is json
+	Approver   Approver    // wallet: optional approver of session request, default approves all
+	Keepalive  *Keepalive  // optional liveness probe of connected session
+}
+
+// Validate check options, the returned error names the offending field
+func (options *Options) Validate() error {
+	switch options.Role {
+	case "", Wallet:
+		if options.URL == "" {
+			return errors.Wrap(ErrParams, "Options.URL is required by wallet")
+		}
+
+		if len(options.Accounts) == 0 {
+			return errors.Wrap(ErrParams, "Options.Accounts is required by wallet")
+		}
+
+		for i, account := range options.Accounts {
+			if account == "" {
+				return errors.Wrap(ErrParams, "Options.Accounts[%d] is empty", i)
+			}
+		}
+
+		_, chainID, err := options.session()
+
+		if err != nil {
+			return err
+		}
+
+		if chainID <= 0 {
+			return errors.Wrap(ErrParams, "Options.ChainID %d must be positive", chainID)
+		}
+	case Dapp:
+		if options.Bridge == "" {
+			return errors.Wrap(ErrParams, "Options.Bridge is required by dapp")
+		}
+
+		if options.ChainID < 0 {
+			return errors.Wrap(ErrParams, "Options.ChainID %d must not be negative", options.ChainID)
+		}
+	default:
+		return errors.Wrap(ErrParams, "Options.Role %s unknown", options.Role)
+	}
+
+	if options.ClientInfo == nil {
+		return errors.Wrap(ErrParams, "Options.ClientInfo is required")
+	}
+
+	if options.Keepalive != nil {
+		if err := options.Keepalive.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if options.Encoding != "" {
+		if _, err := tun4go.GetEncoding(options.Encoding); err != nil {
+			return errors.Wrap(ErrParams, "Options.Encoding %s not registered", options.Encoding)
+		}
+	}
+
+	return nil
+}
+
+// session resolve Accounts and ChainID, CAIP-10 Accounts are converted to addresses and chain id
+func (options *Options) session() ([]string, int64, error) {
+	caip10 := 0
+
+	for _, account := range options.Accounts {
+		if isCAIP10(account) {
+			caip10++
+		}
+	}
+
+	if caip10 == 0 {
+		return options.Accounts, options.ChainID, nil
+	}
+
+	if caip10 != len(options.Accounts) {
+		return nil, 0, errors.Wrap(ErrParams, "Options.Accounts mix CAIP-10 account ids and addresses")
+	}
+
+	accounts, chainID, err := FromCAIP10(options.Accounts)
+
+	if err != nil {
+		return nil, 0, errors.Wrap(ErrParams, "Options.Accounts invalid CAIP-10 account ids: %s", err)
+	}
+
+	if options.ChainID != 0 && options.ChainID != chainID {
+		return nil, 0, errors.Wrap(ErrParams, "Options.ChainID %d mismatch CAIP-10 accounts chain %d", options.ChainID, chainID)
+	}
+
+	return accounts, chainID, nil
+}
+
+// New create wc tunnel with options
+func New(options *Options) (Tunnel, error) {
+	return newTunnel(options)
+}
+
+// optionsFromParams convert tun4go.Params to Options, the keys are:
+// role, url, bridge, account, accounts (comma separated), chainId, clientinfo (json), peerId, encoding,
+// keepaliveInterval and keepaliveTimeout (durations, e.g. 30s)
+func optionsFromParams(params tun4go.Params) (*Options, error) {
+	options := &Options{
+		Role:     Role(params["role"]),
+		URL:      params["url"],
+		Bridge:   params["bridge"],
+		PeerID:   params["peerId"],
+		Encoding: params["encoding"],
+	}
+
+	if account, ok := params["account"]; ok {
+		options.Accounts = append(options.Accounts, account)
+	}
+
+	if accounts, ok := params["accounts"]; ok {
+		for _, account := range strings.Split(accounts, ",") {
+			options.Accounts = append(options.Accounts, strings.TrimSpace(account))
+		}
+	}
+
+	if buff, ok := params["clientinfo"]; ok {
+		if err := json.Unmarshal([]byte(buff), &options.ClientInfo); err != nil {
+			return nil, errors.Wrap(ErrParams, "clientinfo param %s unmarshal error", buff)
+		}
+	}
+
+	if buff, ok := params["chainId"]; ok {
+		chainID, err := strconv.ParseInt(buff, 10, 64)
+
+		if err != nil {
+			return nil, errors.Wrap(ErrParams, "chainId param %s parse error", buff)
+		}
+
+		options.ChainID = chainID
+	}
+
+	if buff, ok := params["keepaliveInterval"]; ok {
+		interval, err := time.ParseDuration(buff)
+
+		if err != nil {
+			return nil, errors.Wrap(ErrParams, "keepaliveInterval param %s parse error", buff)
+		}
+
+		options.Keepalive = &Keepalive{Interval: interval, Timeout: 2 * interval}
+	}
+
+	if buff, ok := params["keepaliveTimeout"]; ok {
+		timeout, err := time.ParseDuration(buff)
+
+		if err != nil {
+			return nil, errors.Wrap(ErrParams, "keepaliveTimeout param %s parse error", buff)
+		}
+
+		if options.Keepalive == nil {
+			return nil, errors.Wrap(ErrParams, "keepaliveTimeout param requires keepaliveInterval")
+		}
+
+		options.Keepalive.Timeout = timeout
+	}
+
+	return options, nil
+}
@@ -0,0 +1,141 @@
+package wc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+const handshake = "wc:15d9f1ea-ea1f-4e37-ac66-e4b33d7d130d@1?bridge=https%3A%2F%2Fbridge.walletconnect.org&key=88f3350f6f374e65b2a82f8682759342e7471cbcd9f3c4d9af58819c11f73870"
+
+func TestOptions(t *testing.T) {
+	tunnel, err := New(&Options{
+		URL:        handshake,
+		Accounts:   []string{account, "0x0000000000000000000000000000000000000001"},
+		ChainID:    1,
+		ClientInfo: &ClientInfo{Name: "wallet"},
+		PeerID:     "self",
+	})
+
+	require.NoError(t, err)
+
+	accounts, chainID := tunnel.Session()
+
+	require.Len(t, accounts, 2)
+	require.Equal(t, int64(1), chainID)
+	require.Equal(t, "self", tunnel.(*wcTunnel).Self)
+
+	tests := []struct {
+		options *Options
+		field   string
+	}{
+		{&Options{Accounts: []string{account}, ChainID: 1, ClientInfo: &ClientInfo{}}, "Options.URL"},
+		{&Options{URL: handshake, ChainID: 1, ClientInfo: &ClientInfo{}}, "Options.Accounts"},
+		{&Options{URL: handshake, Accounts: []string{account}, ClientInfo: &ClientInfo{}}, "Options.ChainID"},
+		{&Options{URL: handshake, Accounts: []string{account}, ChainID: 1}, "Options.ClientInfo"},
+		{&Options{Role: Dapp, ClientInfo: &ClientInfo{}}, "Options.Bridge"},
+		{&Options{Role: "relay"}, "Options.Role"},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.options)
+
+		require.True(t, errors.Is(err, ErrParams))
+		require.True(t, strings.Contains(err.Error(), test.field), "expect error names %s: %s", test.field, err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	params := tun4go.Params{
+		"clientinfo": marshal(&ClientInfo{Name: "wallet"}),
+		"accounts":   account + ", 0x0000000000000000000000000000000000000001",
+		"url":        handshake,
+	}
+
+	// missing chainId must be reported
+	_, err := tun4go.New("wc", params)
+
+	require.True(t, errors.Is(err, ErrParams))
+	require.Contains(t, err.Error(), "Options.ChainID")
+
+	params["chainId"] = "56"
+
+	tunnel, err := tun4go.New("wc", params)
+
+	require.NoError(t, err)
+
+	accounts, chainID := tunnel.(Tunnel).Session()
+
+	require.Equal(t, []string{account, "0x0000000000000000000000000000000000000001"}, accounts)
+	require.Equal(t, int64(56), chainID)
+
+	params["chainId"] = "mainnet"
+
+	_, err = tun4go.New("wc", params)
+
+	require.True(t, errors.Is(err, ErrParams))
+}
+
+func TestCAIP(t *testing.T) {
+	require.Equal(t, "eip155:56", CAIP2(56))
+
+	chainID, err := ChainIDOf("eip155:56")
+
+	require.NoError(t, err)
+	require.Equal(t, int64(56), chainID)
+
+	_, err = ChainIDOf("cosmos:cosmoshub-4")
+
+	require.Error(t, err)
+
+	ids := CAIP10([]string{account}, 56)
+
+	require.Equal(t, []string{"eip155:56:" + account}, ids)
+
+	accounts, chainID, err := FromCAIP10(ids)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{account}, accounts)
+	require.Equal(t, int64(56), chainID)
+
+	_, _, err = FromCAIP10([]string{"eip155:1:" + account, "eip155:56:" + account})
+
+	require.Error(t, err)
+
+	// CAIP-10 accounts carry the chain id
+	tunnel, err := New(&Options{
+		URL:        handshake,
+		Accounts:   ids,
+		ClientInfo: &ClientInfo{Name: "wallet"},
+	})
+
+	require.NoError(t, err)
+
+	accounts, chainID = tunnel.Session()
+
+	require.Equal(t, []string{account}, accounts)
+	require.Equal(t, int64(56), chainID)
+	require.Equal(t, ids, tunnel.Namespaces()["eip155"].Accounts)
+	require.Equal(t, []string{"eip155:56"}, tunnel.Namespaces()["eip155"].Chains)
+
+	_, err = New(&Options{
+		URL:        handshake,
+		Accounts:   ids,
+		ChainID:    1,
+		ClientInfo: &ClientInfo{Name: "wallet"},
+	})
+
+	require.True(t, errors.Is(err, ErrParams))
+
+	_, err = New(&Options{
+		URL:        handshake,
+		Accounts:   append(ids, account),
+		ChainID:    56,
+		ClientInfo: &ClientInfo{Name: "wallet"},
+	})
+
+	require.True(t, errors.Is(err, ErrParams))
+}
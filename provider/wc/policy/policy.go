@@ -0,0 +1,400 @@
+// Package policy implement declarative wc session approval policy
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	neturl "net/url"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/scf4go"
+	_ "github.com/libs4go/scf4go/codec" //
+	"github.com/libs4go/scf4go/reader/file"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go/provider/wc"
+)
+
+// ScopeOfAPIError .
+const errVendor = "policy"
+
+// errors
+var (
+	ErrPolicy = errors.New("policy format error", errors.WithCode(-1), errors.WithVendor(errVendor))
+)
+
+// Policy default actions
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Rule match session request of peer, all set fields must match and empty fields match any.
+// Names, Origins and Icons are patterns where * matches any characters,
+// origin patterns without scheme match the url host
+type Rule struct {
+	Name    string   `json:"name"`    // rule name written to audit log
+	PeerIDs []string `json:"peerIds"` // peer ids
+	Names   []string `json:"names"`   // client name patterns
+	Origins []string `json:"origins"` // client url origin patterns, eg. https://*.example.com
+	Icons   []string `json:"icons"`   // client icon url patterns, any icon matched
+	Chains  []int64  `json:"chains"`  // requested chain ids
+	names   []*regexp.Regexp
+	origins []*regexp.Regexp
+	icons   []*regexp.Regexp
+}
+
+// Policy session approval policy, deny rules are evaluated first, then allowed chains, then allow rules
+type Policy struct {
+	Default string  `json:"default"` // action if no allow rule matched, allow or deny, default is deny
+	Chains  []int64 `json:"chains"`  // allowed requested chain ids, empty means any, see WithDefaultChain
+	Allow   []*Rule `json:"allow"`
+	Deny    []*Rule `json:"deny"`
+}
+
+// Record audit record of policy decision
+type Record struct {
+	Time     time.Time `json:"time"`
+	PeerID   string    `json:"peerId"`
+	Name     string    `json:"name"`
+	Origin   string    `json:"origin"`
+	ChainID  int64     `json:"chainId"`
+	Approved bool      `json:"approved"`
+	Rule     string    `json:"rule,omitempty"` // matched rule name
+	Reason   string    `json:"reason"`
+}
+
+// Auditor write policy decision audit record
+type Auditor interface {
+	Audit(record *Record)
+}
+
+// AuditorFunc function adapter of Auditor
+type AuditorFunc func(record *Record)
+
+// Audit implement Auditor
+func (f AuditorFunc) Audit(record *Record) {
+	f(record)
+}
+
+type logAuditor struct {
+	slf4go.Logger
+}
+
+func (auditor *logAuditor) Audit(record *Record) {
+	buff, _ := json.Marshal(record)
+	auditor.I("{@record}", string(buff))
+}
+
+// Option engine option
+type Option func(engine *Engine)
+
+// WithAuditor set the audit log writer, default writes json records to slf4go logger wc-policy-audit
+func WithAuditor(auditor Auditor) Option {
+	return func(engine *Engine) {
+		engine.auditor = auditor
+	}
+}
+
+// WithDefaultChain set the wallet chain id a session request without chainId is evaluated against,
+// such request is denied if allowed chains are set and the default chain is not
+func WithDefaultChain(chainID int64) Option {
+	return func(engine *Engine) {
+		engine.defaultChain = chainID
+	}
+}
+
+// Engine policy engine, implement wc.Approver
+type Engine struct {
+	policy       *Policy
+	auditor      Auditor
+	defaultChain int64
+}
+
+// New create policy engine
+func New(policy *Policy, opts ...Option) (*Engine, error) {
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		policy:  policy,
+		auditor: &logAuditor{Logger: slf4go.Get("wc-policy-audit")},
+	}
+
+	for _, opt := range opts {
+		opt(engine)
+	}
+
+	return engine, nil
+}
+
+// Load create policy engine from config
+func Load(config scf4go.Values, opts ...Option) (*Engine, error) {
+	var policy *Policy
+
+	if err := config.Scan(&policy); err != nil || policy == nil {
+		return nil, errors.Wrap(ErrPolicy, "scan policy config error")
+	}
+
+	return New(policy, opts...)
+}
+
+// LoadFile create policy engine from yaml or json file, the format is chosen by file extension
+func LoadFile(path string, opts ...Option) (*Engine, error) {
+	config := scf4go.New()
+
+	reader := file.Yaml(path)
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		reader = file.JSON(path)
+	}
+
+	if err := config.Load(file.New(reader)); err != nil {
+		return nil, errors.Wrap(err, "load policy file %s error", path)
+	}
+
+	return Load(config, opts...)
+}
+
+func (policy *Policy) compile() error {
+	switch policy.Default {
+	case "":
+		policy.Default = Deny
+	case Allow, Deny:
+	default:
+		return errors.Wrap(ErrPolicy, "default %s must be allow or deny", policy.Default)
+	}
+
+	for i, rule := range policy.Allow {
+		if err := rule.compile(fmt.Sprintf("allow[%d]", i)); err != nil {
+			return err
+		}
+	}
+
+	for i, rule := range policy.Deny {
+		if err := rule.compile(fmt.Sprintf("deny[%d]", i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rule *Rule) compile(name string) error {
+	if rule == nil {
+		return errors.Wrap(ErrPolicy, "rule %s is empty", name)
+	}
+
+	if rule.Name == "" {
+		rule.Name = name
+	}
+
+	var err error
+
+	if rule.names, err = compilePatterns(rule.Names); err != nil {
+		return errors.Wrap(err, "rule %s names error", rule.Name)
+	}
+
+	if rule.origins, err = compilePatterns(rule.Origins); err != nil {
+		return errors.Wrap(err, "rule %s origins error", rule.Name)
+	}
+
+	if rule.icons, err = compilePatterns(rule.Icons); err != nil {
+		return errors.Wrap(err, "rule %s icons error", rule.Name)
+	}
+
+	return nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return nil, errors.Wrap(ErrPolicy, "empty pattern")
+		}
+
+		expr := "^" + strings.Replace(regexp.QuoteMeta(strings.ToLower(pattern)), `\*`, ".*", -1) + "$"
+
+		compiled = append(compiled, regexp.MustCompile(expr))
+	}
+
+	return compiled, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if pattern.MatchString(strings.ToLower(value)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// request normalized session request fields
+type request struct {
+	peerID  string
+	name    string
+	origin  string
+	host    string
+	icons   []string
+	chainID int64
+}
+
+func newRequest(proposal *wc.SessionProposal) *request {
+	req := &request{
+		peerID:  proposal.PeerID,
+		chainID: proposal.ChainID,
+	}
+
+	if proposal.PeerMeta != nil {
+		req.name = proposal.PeerMeta.Name
+		req.icons = proposal.PeerMeta.ICONs
+
+		if u, err := neturl.Parse(proposal.PeerMeta.URL); err == nil && u.Host != "" {
+			req.origin = u.Scheme + "://" + u.Host
+			req.host = u.Host
+		}
+	}
+
+	return req
+}
+
+func (rule *Rule) match(req *request) bool {
+	if len(rule.PeerIDs) != 0 && !containsString(rule.PeerIDs, req.peerID) {
+		return false
+	}
+
+	if len(rule.names) != 0 && !matchAny(rule.names, req.name) {
+		return false
+	}
+
+	if len(rule.origins) != 0 && !rule.matchOrigin(req) {
+		return false
+	}
+
+	if len(rule.icons) != 0 && !matchAny(rule.icons, req.icons...) {
+		return false
+	}
+
+	if len(rule.Chains) != 0 && !containsInt64(rule.Chains, req.chainID) {
+		return false
+	}
+
+	return true
+}
+
+func (rule *Rule) matchOrigin(req *request) bool {
+	if req.origin == "" {
+		return false
+	}
+
+	for i, pattern := range rule.Origins {
+		value := req.host
+
+		if strings.Contains(pattern, "://") {
+			value = req.origin
+		}
+
+		if rule.origins[i].MatchString(strings.ToLower(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(set []string, value string) bool {
+	for _, s := range set {
+		if s == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsInt64(set []int64, value int64) bool {
+	for _, s := range set {
+		if s == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evaluate evaluate policy of session request without writing audit log
+func (engine *Engine) Evaluate(proposal *wc.SessionProposal) *Record {
+	req := newRequest(proposal)
+
+	// dapp omitting chainId is connected to the wallet default chain
+	if req.chainID == 0 {
+		req.chainID = engine.defaultChain
+	}
+
+	record := &Record{
+		Time:    time.Now(),
+		PeerID:  req.peerID,
+		Name:    req.name,
+		Origin:  req.origin,
+		ChainID: req.chainID,
+	}
+
+	policy := engine.policy
+
+	for _, rule := range policy.Deny {
+		if rule.match(req) {
+			record.Rule = rule.Name
+			record.Reason = fmt.Sprintf("denied by rule %s", rule.Name)
+			return record
+		}
+	}
+
+	if len(policy.Chains) != 0 && !containsInt64(policy.Chains, req.chainID) {
+		if req.chainID == 0 {
+			record.Reason = "chain not requested and wallet default chain unknown"
+		} else {
+			record.Reason = fmt.Sprintf("chain %d not allowed", req.chainID)
+		}
+
+		return record
+	}
+
+	for _, rule := range policy.Allow {
+		if rule.match(req) {
+			record.Approved = true
+			record.Rule = rule.Name
+			record.Reason = fmt.Sprintf("allowed by rule %s", rule.Name)
+			return record
+		}
+	}
+
+	record.Approved = policy.Default == Allow
+	record.Reason = fmt.Sprintf("%s by default", policy.Default)
+
+	return record
+}
+
+// Approve implement wc.Approver, the decision is written to audit log, approved session is connected
+// to the evaluated chain, so the requested or default chain checked by policy is the session chain
+func (engine *Engine) Approve(ctx context.Context, proposal *wc.SessionProposal) (*wc.Decision, error) {
+	record := engine.Evaluate(proposal)
+
+	engine.auditor.Audit(record)
+
+	if !record.Approved {
+		return wc.Reject(record.Reason), nil
+	}
+
+	return wc.Approve(nil, record.ChainID), nil
+}
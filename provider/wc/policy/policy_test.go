@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go/provider/wc"
+	"github.com/stretchr/testify/require"
+)
+
+func proposal(peerID string, url string, chainID int64) *wc.SessionProposal {
+	return &wc.SessionProposal{
+		PeerID:   peerID,
+		PeerMeta: &wc.ClientInfo{Name: "dapp", URL: url},
+		ChainID:  chainID,
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	var records []*Record
+
+	engine, err := LoadFile("./testdata/policy.yaml", WithAuditor(AuditorFunc(func(record *Record) {
+		records = append(records, record)
+	})))
+
+	require.NoError(t, err)
+
+	tests := []struct {
+		proposal *wc.SessionProposal
+		approved bool
+		rule     string
+	}{
+		{proposal("peer", "https://app.uniswap.org/#/swap", 1), true, "uniswap"},
+		{proposal("peer", "https://info.uniswap.org", 0), false, ""},
+		{proposal("peer", "https://claim.uniswap-airdrop.io", 1), false, "phishing"},
+		{proposal("peer", "http://app.uniswap.org", 1), false, "phishing"},
+		{proposal("blocked-peer", "https://app.uniswap.org", 1), false, "deny[1]"},
+		{proposal("peer", "https://app.uniswap.org", 10), false, ""},
+		{proposal("peer", "https://app.sushi.com", 1), false, ""},
+		{&wc.SessionProposal{
+			PeerMeta: &wc.ClientInfo{Name: "ACME Wallet", ICONs: []string{"https://cdn.acme.com/logo.png"}},
+			ChainID:  137,
+		}, true, "internal"},
+		{&wc.SessionProposal{
+			PeerMeta: &wc.ClientInfo{Name: "ACME Wallet", ICONs: []string{"https://cdn.acme.com/logo.png"}},
+			ChainID:  56,
+		}, false, ""},
+	}
+
+	for i, test := range tests {
+		decision, err := engine.Approve(context.Background(), test.proposal)
+
+		require.NoError(t, err)
+		require.Equal(t, test.approved, decision.Approved, "case %d", i)
+		require.Equal(t, test.rule, records[i].Rule, "case %d", i)
+		require.Equal(t, test.approved, records[i].Approved, "case %d", i)
+
+		if test.approved {
+			require.Equal(t, records[i].ChainID, decision.ChainID, "case %d", i)
+		} else {
+			require.Equal(t, records[i].Reason, decision.Reason)
+		}
+	}
+
+	require.Len(t, records, len(tests))
+}
+
+func TestDefaultChain(t *testing.T) {
+	engine, err := LoadFile("./testdata/policy.yaml", WithDefaultChain(1))
+
+	require.NoError(t, err)
+
+	// missing chainId is the wallet default chain
+	record := engine.Evaluate(proposal("peer", "https://info.uniswap.org", 0))
+
+	require.True(t, record.Approved)
+	require.Equal(t, int64(1), record.ChainID)
+
+	decision, err := engine.Approve(context.Background(), proposal("peer", "https://info.uniswap.org", 0))
+
+	require.NoError(t, err)
+	require.Equal(t, int64(1), decision.ChainID)
+
+	engine, err = LoadFile("./testdata/policy.yaml", WithDefaultChain(10))
+
+	require.NoError(t, err)
+
+	record = engine.Evaluate(proposal("peer", "https://info.uniswap.org", 0))
+
+	require.False(t, record.Approved)
+	require.Equal(t, "chain 10 not allowed", record.Reason)
+
+	// without allowed chains missing chainId is not checked
+	engine, err = LoadFile("./testdata/policy.json")
+
+	require.NoError(t, err)
+	require.True(t, engine.Evaluate(proposal("peer", "https://any.org", 0)).Approved)
+}
+
+func TestJSONPolicy(t *testing.T) {
+	engine, err := LoadFile("./testdata/policy.json")
+
+	require.NoError(t, err)
+
+	require.True(t, engine.Evaluate(proposal("peer", "https://any.org", 1)).Approved)
+
+	record := engine.Evaluate(&wc.SessionProposal{PeerMeta: &wc.ClientInfo{Name: "Evil Dapp"}})
+
+	require.False(t, record.Approved)
+	require.Equal(t, "evil", record.Rule)
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	_, err := New(&Policy{Default: "maybe"})
+
+	require.True(t, errors.Is(err, ErrPolicy))
+
+	_, err = New(&Policy{Allow: []*Rule{{Names: []string{""}}}})
+
+	require.True(t, errors.Is(err, ErrPolicy))
+}
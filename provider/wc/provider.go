@@ -1,6 +1,9 @@
 package wc
 
-import "github.com/libs4go/tun4go"
+import (
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/transport/ws"
+)
 
 type wcProvider struct {
 }
@@ -21,6 +24,17 @@ func (provider *wcProvider) New(params tun4go.Params) (tun4go.Tunnel, error) {
 	return newWCTunnel(params)
 }
 
+// NewTransport dial to the bridge of wc url
+func (provider *wcProvider) NewTransport(uri string) (tun4go.Transport, error) {
+	u, err := ParseURL(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ws.Dial(u.Bridge)
+}
+
 func init() {
-	tun4go.RegisterProvider(newWCProvider())
+	tun4go.RegisterProvider(newWCProvider(), "wc")
 }
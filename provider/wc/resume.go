@@ -0,0 +1,167 @@
+package wc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/internal/jsonrpc"
+	"github.com/libs4go/tun4go/rpc"
+)
+
+type resumeOptions struct {
+	refresh bool
+	probe   string
+}
+
+// ResumeOption session resume option
+type ResumeOption func(options *resumeOptions)
+
+// WithRefresh send wc_sessionUpdate with current accounts and chain id to peer after resume,
+// only wallet side can refresh session
+func WithRefresh() ResumeOption {
+	return func(options *resumeOptions) {
+		options.refresh = true
+	}
+}
+
+// WithProbe send a json rpc request with method to peer and wait the response,
+// any response include error response proves the peer is still reachable.
+// msgs recv while waiting are kept and returned by later Recv calls, use ResumeContext to limit the waiting
+func WithProbe(method string) ResumeOption {
+	return func(options *resumeOptions) {
+		options.probe = method
+	}
+}
+
+// Resume re-subscribe self topic on the new transport, Connect is a no-op for the session restored by FromContext.
+// Session of PeerLost status turns back to Connected after resumed
+func (tunnel *wcTunnel) Resume(transport tun4go.Transport, opts ...ResumeOption) error {
+	return tunnel.ResumeContext(context.Background(), transport, opts...)
+}
+
+// ResumeContext context aware Resume, use ctx deadline to limit the probe waiting
+func (tunnel *wcTunnel) ResumeContext(ctx context.Context, transport tun4go.Transport, opts ...ResumeOption) error {
+
+	if status := tunnel.Status(); status != Connected && status != PeerLost {
+		return errors.Wrap(ErrStatus, "resume session with invalid status %s", status)
+	}
+
+	if err := tunnel.checkKeepalive(transport); err != nil {
+		return err
+	}
+
+	options := &resumeOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.refresh && tunnel.Role != Wallet {
+		return errors.Wrap(ErrParams, "only wallet can refresh session")
+	}
+
+	err := tunnel.subscribe(ctx, tunnel.Self, transport)
+
+	if err != nil {
+		return err
+	}
+
+	if options.refresh {
+		accounts, chainID := tunnel.Session()
+
+		if err := tunnel.sendSessionUpdate(ctx, true, accounts, chainID, transport); err != nil {
+			return err
+		}
+	}
+
+	if options.probe != "" {
+		if err := tunnel.probe(ctx, options.probe, transport); err != nil {
+			return err
+		}
+	}
+
+	tunnel.mutex.Lock()
+
+	if tunnel.State == PeerLost {
+		tunnel.State = Connected
+	}
+
+	tunnel.mutex.Unlock()
+
+	tunnel.alive()
+
+	return nil
+}
+
+func (tunnel *wcTunnel) probe(ctx context.Context, method string, transport tun4go.Transport) error {
+	probe := &jsonRPCRequest{
+		ID:      jsonrpc.NewID(),
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  []interface{}{},
+	}
+
+	buff, err := json.Marshal(probe)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal probe request error")
+	}
+
+	if err := tunnel.doSend(ctx, buff, transport); err != nil {
+		return err
+	}
+
+	// msgs recv before probe response, including undecodable frames, are left for Recv
+	var backlog []recvFrame
+
+	defer func() {
+		tunnel.mutex.Lock()
+		tunnel.backlog = append(tunnel.backlog, backlog...)
+		tunnel.mutex.Unlock()
+	}()
+
+	for {
+		data, err := tun4go.ReadContext(ctx, transport)
+
+		if err != nil {
+			return errors.Wrap(err, "read probe response error")
+		}
+
+		buff, err := tunnel.read(data)
+
+		if err != nil {
+			backlog = append(backlog, recvFrame{err: errors.Wrap(err, "decode recv msg error : %s", string(data))})
+			continue
+		}
+
+		msg, err := rpc.Parse(buff)
+
+		if err != nil {
+			backlog = append(backlog, recvFrame{buff: buff})
+			continue
+		}
+
+		if msg.Kind == rpc.KindResponse && jsonrpc.SameID(msg.Response.ID, probe.ID) {
+			return nil
+		}
+
+		if msg.Kind == rpc.KindRequest && msg.Request.Method == "wc_sessionUpdate" {
+			request, err := tunnel.readJSONRPCRequest(buff)
+
+			if err != nil {
+				backlog = append(backlog, recvFrame{buff: buff})
+				continue
+			}
+
+			if err := tunnel.handleSessionUpdate(request); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		backlog = append(backlog, recvFrame{buff: buff})
+	}
+}
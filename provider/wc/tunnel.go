@@ -1,28 +1,105 @@
 package wc
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/libs4go/errors"
 	"github.com/libs4go/slf4go"
 	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/caip"
+	"github.com/libs4go/tun4go/internal/jsonrpc"
+	"github.com/libs4go/tun4go/rpc"
 )
 
 // Status Tunnel status
-type Status string
+type Status = tun4go.Status
 
 // Status enum
 const (
-	Connecting    Status = "connecting"
-	Connected     Status = "connected"
-	Disconnecting Status = "disconnecting"
-	Disconnected  Status = "disconnected"
+	Connecting    = tun4go.Connecting
+	Connected     = tun4go.Connected
+	Disconnecting = tun4go.Disconnecting
+	Disconnected  = tun4go.Disconnected
 )
 
-type clientInfo struct {
+// Role tunnel side role
+type Role string
+
+// Role enum
+const (
+	Wallet Role = "wallet" // responder, pair with handshake url provide by dapp
+	Dapp   Role = "dapp"   // initiator, create handshake url and wait wallet approve
+)
+
+// Tunnel wc tunnel object with session accessors.
+//
+// Tunnel is safe for concurrent use with one reader goroutine calling Connect, Resume and Recv,
+// and any number of goroutines calling Send, Update, Disconnect, Context and the accessors.
+// Transport io is done without holding the tunnel lock, so the transport Write must be safe for
+// concurrent use if Send is called from several goroutines. A Disconnect does not interrupt a
+// blocking Recv, the next Recv returns ErrStatus once the blocking read returns.
+type Tunnel interface {
+	tun4go.StatusTunnel
+	tun4go.ContextTunnel
+
+	// HandshakeURL get the handshake url peer should pair with
+	HandshakeURL() *URL
+
+	// Session get the session approved accounts and chain id
+	Session() (accounts []string, chainID int64)
+
+	// Namespaces get the session accounts as CAIP-25 eip155 session namespace,
+	// returns nil before session approved
+	Namespaces() map[string]*caip.SessionNamespace
+
+	// Resume resume the session restored by FromContext over new transport
+	Resume(transport tun4go.Transport, options ...ResumeOption) error
+
+	// ResumeContext context aware Resume
+	ResumeContext(ctx context.Context, transport tun4go.Transport, options ...ResumeOption) error
+
+	// Update push new accounts and chain id to peer, only wallet side can update session
+	Update(accounts []string, chainID int64, transport tun4go.Transport) error
+
+	// SetKeepalive set the liveness probe of connected session, nil disable it,
+	// the transport passed to Connect, Resume and Recv must be a tun4go.ContextTransport
+	SetKeepalive(keepalive *Keepalive) error
+
+	// SetApprover set the approver deciding session request of peer dapp, wallet tunnel
+	// without approver approves every session request
+	SetApprover(approver Approver)
+
+	// RecvMessage recv msg and classify it as json rpc request, notification, response or batch,
+	// returns rpc.ErrFormat if msg is not a json rpc message
+	RecvMessage(transport tun4go.Transport) (*rpc.Message, error)
+
+	// RecvMessageContext context aware RecvMessage
+	RecvMessageContext(ctx context.Context, transport tun4go.Transport) (*rpc.Message, error)
+
+	// OnSessionChanged add listener of session update approved by peer wallet,
+	// listener is called in the Recv goroutine
+	OnSessionChanged(listener SessionListener)
+}
+
+// SessionEvent session changed event
+type SessionEvent struct {
+	Peer     string
+	Accounts []string
+	ChainID  int64
+}
+
+// SessionListener session changed event listener
+type SessionListener func(event *SessionEvent)
+
+// ClientInfo peer client metadata
+type ClientInfo struct {
 	Description string   `json:"description"`
 	URL         string   `json:"url,omitempty"`
 	ICONs       []string `json:"icons,omitempty"`
@@ -31,79 +108,105 @@ type clientInfo struct {
 
 type wcTunnel struct {
 	slf4go.Logger `json:"-"`
+	Role          Role        `json:"role"`
 	URL           *URL        `json:"url"`
 	Self          string      `json:"self"`
-	SelfInfo      *clientInfo `json:"self-info"`
+	SelfInfo      *ClientInfo `json:"self-info"`
 	Key           []byte      `json:"key"`
-	PeerInfo      *clientInfo `json:"peer-info"`
+	PeerInfo      *ClientInfo `json:"peer-info"`
 	Peer          string      `json:"peer"`
 	ChainID       int64       `json:"chain-id"`
 	Accounts      []string    `json:"accounts"`
-	Status        Status      `json:"status"`
+	State         Status      `json:"status"`
+	Encoding      string      `json:"encoding,omitempty"` // envelope encoding name, default is json
+	backlog       []recvFrame // msg recv before Recv called
+	listeners     []SessionListener
+	approver      Approver
+	keepalive     *Keepalive
+	seen          time.Time      // last time msg recv from peer
+	probing       bool           // keepalive probe sent since seen
+	probes        map[int64]bool // ids of keepalive probe waiting response
+	mutex         sync.RWMutex   // guard State, Peer, PeerInfo, Accounts, ChainID, backlog, listeners, approver and keepalive
 }
 
 func newWCTunnel(params tun4go.Params) (*wcTunnel, error) {
+	options, err := optionsFromParams(params)
 
-	url, ok := params["url"]
-
-	if !ok {
-		return nil, errors.Wrap(ErrParams, "expect handshake url param")
+	if err != nil {
+		return nil, err
 	}
 
-	u, err := ParseURL(url)
+	return newTunnel(options)
+}
 
-	if err != nil {
+func newTunnel(options *Options) (*wcTunnel, error) {
+
+	if err := options.Validate(); err != nil {
 		return nil, err
 	}
 
-	key, err := hex.DecodeString(u.Key)
+	tunnel := &wcTunnel{
+		Logger:    slf4go.Get("wc-tunnel"),
+		Role:      options.Role,
+		Self:      options.PeerID,
+		State:     Disconnected,
+		Accounts:  options.Accounts,
+		SelfInfo:  options.ClientInfo,
+		ChainID:   options.ChainID,
+		Encoding:  options.Encoding,
+		approver:  options.Approver,
+		keepalive: options.Keepalive,
+	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "decode key %s error", u.Key)
+	if tunnel.Role != Dapp {
+		tunnel.Accounts, tunnel.ChainID, _ = options.session()
 	}
 
-	account, ok := params["account"]
+	if tunnel.Role == "" {
+		tunnel.Role = Wallet
+	}
 
-	if !ok {
-		return nil, errors.Wrap(ErrParams, "expect account param")
+	if tunnel.Self == "" {
+		tunnel.Self = uuid.NewString()
 	}
 
-	var ci *clientInfo
+	if tunnel.Role == Dapp {
+		var key [32]byte
 
-	buff, ok := params["clientinfo"]
+		_, err := rand.Read(key[:])
 
-	if !ok {
-		return nil, errors.Wrap(ErrParams, "expect clientinfo param")
-	}
+		if err != nil {
+			return nil, errors.Wrap(err, "generate key error")
+		}
 
-	err = json.Unmarshal([]byte(buff), &ci)
+		tunnel.Key = key[:]
 
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshal clientinfo param error")
+		tunnel.URL = &URL{
+			Topic:   uuid.NewString(),
+			Version: "1",
+			Bridge:  options.Bridge,
+			Key:     hex.EncodeToString(key[:]),
+		}
+
+		return tunnel, nil
 	}
 
-	buff, ok = params["chainId"]
+	u, err := ParseURL(options.URL)
 
 	if err != nil {
-		return nil, errors.Wrap(ErrParams, "expect chainId param")
+		return nil, errors.Wrap(ErrParams, "Options.URL %s parse error", options.URL)
 	}
 
-	chainID, err := strconv.Atoi(buff)
+	key, err := hex.DecodeString(u.Key)
 
-	if err != nil {
-		return nil, errors.Wrap(err, "parse chainId %s error", buff)
+	if err != nil || len(key) != 32 {
+		return nil, errors.Wrap(ErrParams, "Options.URL key %s must be 32 bytes hex", u.Key)
 	}
 
-	return &wcTunnel{
-		Logger:   slf4go.Get("wc-tunnel"),
-		Self:     uuid.NewString(),
-		Status:   Disconnected,
-		Accounts: []string{account},
-		SelfInfo: ci,
-		URL:      u,
-		Key:      key,
-		ChainID:  int64(chainID),
-	}, nil
+	tunnel.URL = u
+	tunnel.Key = key
+
+	return tunnel, nil
 }
 
 func fromContext(context []byte) (*wcTunnel, error) {
@@ -114,14 +217,95 @@ func fromContext(context []byte) (*wcTunnel, error) {
 		return nil, errors.Wrap(err, "unmarshal wcTunnel context error")
 	}
 
+	tunnel.Logger = slf4go.Get("wc-tunnel")
+
+	if tunnel.Role == "" {
+		tunnel.Role = Wallet
+	}
+
 	return tunnel, nil
 }
 
+func (tunnel *wcTunnel) Status() tun4go.Status {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	return tunnel.State
+}
+
+func (tunnel *wcTunnel) setStatus(status Status) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.State = status
+}
+
+// connected get the peer of connected session
+func (tunnel *wcTunnel) connected(action string) (string, error) {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	if tunnel.State != Connected {
+		return "", errors.Wrap(ErrStatus, "%s with invalid status %s", action, tunnel.State)
+	}
+
+	return tunnel.Peer, nil
+}
+
+// setSession set the session approved by peer
+func (tunnel *wcTunnel) setSession(peer string, peerInfo *ClientInfo, accounts []string, chainID int64) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.Peer = peer
+	tunnel.PeerInfo = peerInfo
+	tunnel.Accounts = accounts
+	tunnel.ChainID = chainID
+}
+
+func (tunnel *wcTunnel) HandshakeURL() *URL {
+	return tunnel.URL
+}
+
+func (tunnel *wcTunnel) Session() ([]string, int64) {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	return append([]string(nil), tunnel.Accounts...), tunnel.ChainID
+}
+
+func (tunnel *wcTunnel) Namespaces() map[string]*caip.SessionNamespace {
+	return namespaces(tunnel.Session())
+}
+
+func (tunnel *wcTunnel) SetApprover(approver Approver) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.approver = approver
+}
+
+func (tunnel *wcTunnel) OnSessionChanged(listener SessionListener) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.listeners = append(tunnel.listeners, listener)
+}
+
 func (tunnel *wcTunnel) send(topic string, data []byte) ([]byte, error) {
 
 	tunnel.D("send msg {@msg}", string(data))
 
-	var msg *socketMessage = nil
+	encoding, err := tunnel.encoding()
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &socketMessage{
+		Topic: topic,
+		Type:  "pub",
+	}
 
 	if len(data) != 0 {
 		encryptData, err := encrypt(data, tunnel.Key)
@@ -130,27 +314,14 @@ func (tunnel *wcTunnel) send(topic string, data []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		payload, err := json.Marshal(encryptData)
+		msg.Payload, err = encoding.Marshal(encryptData)
 
 		if err != nil {
 			return nil, errors.Wrap(err, "marshal encryptionPayload error")
 		}
-
-		msg = &socketMessage{
-			Topic:   topic,
-			Type:    "pub",
-			Payload: string(payload),
-		}
-
-	} else {
-		msg = &socketMessage{
-			Topic:   topic,
-			Type:    "pub",
-			Payload: "",
-		}
 	}
 
-	buff, err := json.Marshal(msg)
+	buff, err := encoding.Marshal(msg)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "marshal socketMessage error")
@@ -160,22 +331,36 @@ func (tunnel *wcTunnel) send(topic string, data []byte) ([]byte, error) {
 }
 
 func (tunnel *wcTunnel) Send(msg []byte, transport tun4go.Transport) error {
+	return tunnel.SendContext(context.Background(), msg, transport)
+}
+
+func (tunnel *wcTunnel) SendContext(ctx context.Context, msg []byte, transport tun4go.Transport) error {
+
+	peer, err := tunnel.connected("send msg")
 
-	if tunnel.Status != Connected {
-		return errors.Wrap(ErrStatus, "send msg with invalid status %s", tunnel.Status)
+	if err != nil {
+		return err
 	}
 
-	return tunnel.doSend(msg, transport)
+	return tunnel.publish(ctx, peer, msg, transport)
+}
+
+func (tunnel *wcTunnel) doSend(ctx context.Context, msg []byte, transport tun4go.Transport) error {
+	tunnel.mutex.RLock()
+	peer := tunnel.Peer
+	tunnel.mutex.RUnlock()
+
+	return tunnel.publish(ctx, peer, msg, transport)
 }
 
-func (tunnel *wcTunnel) doSend(msg []byte, transport tun4go.Transport) error {
-	buff, err := tunnel.send(tunnel.Peer, msg)
+func (tunnel *wcTunnel) publish(ctx context.Context, topic string, msg []byte, transport tun4go.Transport) error {
+	buff, err := tunnel.send(topic, msg)
 
 	if err != nil {
 		return err
 	}
 
-	err = transport.Write(buff)
+	err = tun4go.WriteContext(ctx, transport, buff)
 
 	if err != nil {
 		return errors.Wrap(err, "write to transport error")
@@ -185,16 +370,104 @@ func (tunnel *wcTunnel) doSend(msg []byte, transport tun4go.Transport) error {
 }
 
 func (tunnel *wcTunnel) Recv(transport tun4go.Transport) ([]byte, error) {
+	return tunnel.RecvContext(context.Background(), transport)
+}
+
+func (tunnel *wcTunnel) RecvContext(ctx context.Context, transport tun4go.Transport) ([]byte, error) {
 
 Start:
 
-	if tunnel.Status != Connected {
-		return nil, errors.Wrap(ErrStatus, "send msg with invalid status %s", tunnel.Status)
+	if _, err := tunnel.connected("recv msg"); err != nil {
+		return nil, err
 	}
 
-	data, err := transport.Read()
+	buff, err := tunnel.next(ctx, transport)
 
 	if err != nil {
+		return nil, err
+	}
+
+	if elements, ok := readJSONRPCBatch(buff); ok {
+		buff, err = tunnel.handleBatch(ctx, elements, transport)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if buff == nil {
+			goto Start
+		}
+
+		return buff, nil
+	}
+
+	if handled, rsp, err := tunnel.answerProbe(buff); err != nil {
+		return nil, err
+	} else if handled {
+		if rsp != nil {
+			if err := tunnel.doSend(ctx, rsp, transport); err != nil {
+				return nil, err
+			}
+		}
+
+		goto Start
+	}
+
+	// msg which is not a wc_sessionUpdate request is passed to caller as is, include malformed one
+	if handled, err := tunnel.handleSessionUpdateMsg(buff); err != nil {
+		return nil, err
+	} else if handled {
+		goto Start
+	}
+
+	return buff, nil
+}
+
+func (tunnel *wcTunnel) RecvMessage(transport tun4go.Transport) (*rpc.Message, error) {
+	return tunnel.RecvMessageContext(context.Background(), transport)
+}
+
+func (tunnel *wcTunnel) RecvMessageContext(ctx context.Context, transport tun4go.Transport) (*rpc.Message, error) {
+	buff, err := tunnel.RecvContext(ctx, transport)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := rpc.Parse(buff)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "classify recv msg error")
+	}
+
+	return msg, nil
+}
+
+// recvFrame decrypted msg or decode error of frame recv before Recv called
+type recvFrame struct {
+	buff []byte
+	err  error
+}
+
+func (tunnel *wcTunnel) next(ctx context.Context, transport tun4go.Transport) ([]byte, error) {
+	tunnel.mutex.Lock()
+
+	if len(tunnel.backlog) != 0 {
+		frame := tunnel.backlog[0]
+		tunnel.backlog = tunnel.backlog[1:]
+		tunnel.mutex.Unlock()
+		return frame.buff, frame.err
+	}
+
+	tunnel.mutex.Unlock()
+
+	data, err := tunnel.readAlive(ctx, transport)
+
+	if err != nil {
+		if errors.Is(err, ErrPeerLost) {
+			return nil, err
+		}
+
 		return nil, errors.Wrap(err, "read from trasnport error")
 	}
 
@@ -204,22 +477,82 @@ Start:
 		return nil, errors.Wrap(err, "decode recv msg error : %s", string(data))
 	}
 
-	request, err := tunnel.readJSONRPCRequest(buff)
+	return buff, nil
+}
 
-	if request.Method == "wc_sessionUpdate" {
-		if err := tunnel.handleSessionUpdate(request); err != nil {
+// handleBatch handle keepalive probes and wc_sessionUpdate requests in json rpc batch,
+// probe responses are sent back as one batch, returns the batch of remaining elements or nil if none remains
+func (tunnel *wcTunnel) handleBatch(ctx context.Context, elements []json.RawMessage, transport tun4go.Transport) ([]byte, error) {
+	var remains, responses []json.RawMessage
+
+	for _, element := range elements {
+		handled, rsp, err := tunnel.answerProbe(element)
+
+		if err != nil {
 			return nil, err
 		}
-		goto Start
+
+		if handled {
+			if rsp != nil {
+				responses = append(responses, rsp)
+			}
+
+			continue
+		}
+
+		if handled, err := tunnel.handleSessionUpdateMsg(element); err != nil {
+			return nil, err
+		} else if handled {
+			continue
+		}
+
+		remains = append(remains, element)
+	}
+
+	if len(responses) != 0 {
+		buff, err := json.Marshal(responses)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal keepalive probe responses error")
+		}
+
+		if err := tunnel.doSend(ctx, buff, transport); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(remains) == 0 {
+		return nil, nil
+	}
+
+	buff, err := json.Marshal(remains)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal json rpc batch error")
 	}
 
 	return buff, nil
 }
 
+// handleSessionUpdateMsg handle msg if it is a wc_sessionUpdate request, returns true if msg is handled
+func (tunnel *wcTunnel) handleSessionUpdateMsg(buff []byte) (bool, error) {
+	if msg, err := rpc.Parse(buff); err != nil || msg.Kind != rpc.KindRequest || msg.Request.Method != "wc_sessionUpdate" {
+		return false, nil
+	}
+
+	request, err := tunnel.readJSONRPCRequest(buff)
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, tunnel.handleSessionUpdate(request)
+}
+
 func (tunnel *wcTunnel) handleSessionUpdate(request *jsonRPCRequest) error {
 
 	if len(request.Params) != 1 {
-		return errors.Wrap(ErrFormat, "wc_ssessionUpdate params number must be 1")
+		return errors.Wrap(ErrSessionUpdate, "wc_sessionUpdate params number must be 1")
 	}
 
 	buff, err := json.Marshal(request.Params[0])
@@ -232,41 +565,95 @@ func (tunnel *wcTunnel) handleSessionUpdate(request *jsonRPCRequest) error {
 
 	err = json.Unmarshal(buff, &update)
 
-	if err != nil {
-		return errors.Wrap(err, "unmarshal sessionUpdate request error")
+	if err != nil || update == nil {
+		return errors.Wrap(ErrSessionUpdate, "unmarshal sessionUpdate request error: %s", string(buff))
+	}
+
+	tunnel.mutex.Lock()
+
+	if update.Approved == false {
+		defer tunnel.mutex.Unlock()
+
+		if tunnel.State == Connected {
+			tunnel.State = Disconnected
+			return errors.Wrap(ErrDisconnected, "peer %s disconnct", tunnel.Peer)
+		}
+
+		return nil
+	}
+
+	// only wallet owns the session accounts and chain id
+	if tunnel.Role != Dapp {
+		peer := tunnel.Peer
+		tunnel.mutex.Unlock()
+		tunnel.W("skip approved session update from dapp {@peer}", peer)
+		return nil
+	}
+
+	if len(update.Accounts) == 0 {
+		tunnel.mutex.Unlock()
+		return errors.Wrap(ErrSessionUpdate, "approved sessionUpdate expect accounts: %s", string(buff))
+	}
+
+	tunnel.Accounts = update.Accounts
+	tunnel.ChainID = update.ChainID
+
+	event := &SessionEvent{
+		Peer:     tunnel.Peer,
+		Accounts: append([]string(nil), update.Accounts...),
+		ChainID:  update.ChainID,
 	}
 
-	if update.Approved == false && tunnel.Status == Connected {
-		tunnel.Status = Disconnected
-		return errors.Wrap(ErrDisconnected, "peer %s disconnct", tunnel.Peer)
+	listeners := append([]SessionListener(nil), tunnel.listeners...)
+
+	tunnel.mutex.Unlock()
+
+	// listeners are called without lock, so they can access the tunnel
+	for _, listener := range listeners {
+		listener(event)
 	}
 
 	return nil
 }
 
 func (tunnel *wcTunnel) read(data []byte) ([]byte, error) {
+	encoding, err := tunnel.encoding()
+
+	if err != nil {
+		return nil, err
+	}
+
 	var msg *socketMessage
 
-	err := json.Unmarshal(data, &msg)
+	err = encoding.Unmarshal(data, &msg)
 
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshal socketMessage error %s", string(data))
+	if err != nil || msg == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal socketMessage error %s", string(data))
 	}
 
 	var encryptData *encryptionPayload
 
-	err = json.Unmarshal([]byte(msg.Payload), &encryptData)
+	err = encoding.Unmarshal(msg.Payload, &encryptData)
 
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshal encryptionPayload error %s", msg.Payload)
+	if err != nil || encryptData == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal encryptionPayload error %s", string(msg.Payload))
 	}
 
-	buff, err := encryptData.decrypt(tunnel.Key)
+	return encryptData.decrypt(tunnel.Key)
+}
 
-	return buff, err
+func (tunnel *wcTunnel) encoding() (tun4go.Encoding, error) {
+	if tunnel.Encoding == "" {
+		return tun4go.GetEncoding(tun4go.JSON)
+	}
+
+	return tun4go.GetEncoding(tunnel.Encoding)
 }
 
 func (tunnel *wcTunnel) Context() ([]byte, error) {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
 	buff, err := json.Marshal(&tunnel)
 
 	if err != nil {
@@ -278,15 +665,63 @@ func (tunnel *wcTunnel) Context() ([]byte, error) {
 
 // Disconnect send disconnect msg to peer
 func (tunnel *wcTunnel) Disconnect(transport tun4go.Transport) error {
+	return tunnel.DisconnectContext(context.Background(), transport)
+}
+
+// DisconnectContext send disconnect msg to peer
+func (tunnel *wcTunnel) DisconnectContext(ctx context.Context, transport tun4go.Transport) error {
+
+	accounts, chainID := tunnel.Session()
+
+	err := tunnel.sendSessionUpdate(ctx, false, accounts, chainID, transport)
 
+	if err != nil {
+		return err
+	}
+
+	tunnel.setStatus(Disconnected)
+
+	return nil
+}
+
+// Update push new accounts and chain id to peer
+func (tunnel *wcTunnel) Update(accounts []string, chainID int64, transport tun4go.Transport) error {
+
+	if tunnel.Role != Wallet {
+		return errors.Wrap(ErrParams, "only wallet can update session")
+	}
+
+	if _, err := tunnel.connected("update session"); err != nil {
+		return err
+	}
+
+	if len(accounts) == 0 {
+		return errors.Wrap(ErrParams, "update session expect accounts")
+	}
+
+	err := tunnel.sendSessionUpdate(context.Background(), true, accounts, chainID, transport)
+
+	if err != nil {
+		return err
+	}
+
+	tunnel.mutex.Lock()
+	tunnel.Accounts = append([]string(nil), accounts...)
+	tunnel.ChainID = chainID
+	tunnel.mutex.Unlock()
+
+	return nil
+}
+
+func (tunnel *wcTunnel) sendSessionUpdate(ctx context.Context, approved bool, accounts []string, chainID int64, transport tun4go.Transport) error {
 	rsp := &sessionUpdate{
-		ChainID:  tunnel.ChainID,
-		Approved: false,
-		Accounts: tunnel.Accounts,
+		ChainID:  chainID,
+		Approved: approved,
+		Accounts: accounts,
 	}
 
 	rpc := &jsonRPCRequest{
-		ID:      1,
+		ID:      jsonrpc.NewID(),
 		JSONRPC: "2.0",
 		Params:  []interface{}{rsp},
 		Method:  "wc_sessionUpdate",
@@ -295,34 +730,31 @@ func (tunnel *wcTunnel) Disconnect(transport tun4go.Transport) error {
 	buff, err := json.Marshal(rpc)
 
 	if err != nil {
-		return errors.Wrap(err, "marshal sessionResponse error")
+		return errors.Wrap(err, "marshal sessionUpdate error")
 	}
 
-	err = tunnel.doSend(buff, transport)
+	return tunnel.doSend(ctx, buff, transport)
+}
+
+func (tunnel *wcTunnel) subscribe(ctx context.Context, topic string, transport tun4go.Transport) error {
+	encoding, err := tunnel.encoding()
 
 	if err != nil {
 		return err
 	}
 
-	tunnel.Status = Disconnected
-
-	return nil
-}
-
-func (tunnel *wcTunnel) subscribe(topic string, transport tun4go.Transport) error {
 	msg := &socketMessage{
-		Topic:   topic,
-		Type:    "sub",
-		Payload: "",
+		Topic: topic,
+		Type:  "sub",
 	}
 
-	buff, err := json.Marshal(msg)
+	buff, err := encoding.Marshal(msg)
 
 	if err != nil {
 		return errors.Wrap(err, "marshal socketMessage error")
 	}
 
-	err = transport.Write(buff)
+	err = tun4go.WriteContext(ctx, transport, buff)
 
 	if err != nil {
 		return errors.Wrap(err, "write sub %s to transport error", topic)
@@ -332,59 +764,176 @@ func (tunnel *wcTunnel) subscribe(topic string, transport tun4go.Transport) erro
 }
 
 func (tunnel *wcTunnel) Connect(transport tun4go.Transport) error {
+	return tunnel.ConnectContext(context.Background(), transport)
+}
+
+func (tunnel *wcTunnel) ConnectContext(ctx context.Context, transport tun4go.Transport) error {
+
+	if err := tunnel.checkKeepalive(transport); err != nil {
+		return err
+	}
+
+	tunnel.mutex.Lock()
 
-	if tunnel.Status != Disconnected {
+	if tunnel.State == PeerLost {
+		tunnel.mutex.Unlock()
+		return errors.Wrap(ErrStatus, "session with status %s, Resume it to continue", PeerLost)
+	}
+
+	if tunnel.State != Disconnected {
+		tunnel.mutex.Unlock()
 		return nil
 	}
 
-	tunnel.Status = Connecting
+	tunnel.State = Connecting
+
+	tunnel.mutex.Unlock()
+
+	var err error
+
+	if tunnel.Role == Dapp {
+		err = tunnel.connectDapp(ctx, transport)
+	} else {
+		err = tunnel.connectWallet(ctx, transport)
+	}
+
+	if err != nil {
+		tunnel.setStatus(Disconnected)
+		return err
+	}
+
+	tunnel.setStatus(Connected)
+	tunnel.alive()
+
+	return nil
+}
+
+func (tunnel *wcTunnel) connectWallet(ctx context.Context, transport tun4go.Transport) error {
 
-	err := tunnel.subscribe(tunnel.URL.Topic, transport)
+	err := tunnel.subscribe(ctx, tunnel.URL.Topic, transport)
 
 	if err != nil {
-		tunnel.Status = Disconnected
 		return err
 	}
 
-	buff, err := transport.Read()
+	buff, err := tun4go.ReadContext(ctx, transport)
 
 	if err != nil {
-		tunnel.Status = Disconnected
 		return errors.Wrap(err, "read sessionRequest error")
 	}
 
 	buff, err = tunnel.read(buff)
 
 	if err != nil {
-		tunnel.Status = Disconnected
 		return err
 	}
 
 	request, err := tunnel.readJSONRPCRequest(buff)
 
 	if err != nil {
-		tunnel.Status = Disconnected
 		return err
 	}
 
 	if request.Method != "wc_sessionRequest" {
-		tunnel.Status = Disconnected
 		return errors.Wrap(ErrMessage, "expect wc_sessionRequest but got %s", request.Method)
 	}
 
-	err = tunnel.handleSessionRequest(request, transport)
+	return tunnel.handleSessionRequest(ctx, request, transport)
+}
+
+func (tunnel *wcTunnel) connectDapp(ctx context.Context, transport tun4go.Transport) error {
+
+	err := tunnel.subscribe(ctx, tunnel.Self, transport)
+
+	if err != nil {
+		return err
+	}
+
+	sr := &sessionRequest{
+		PeerID:   tunnel.Self,
+		PeerMeta: tunnel.SelfInfo,
+	}
+
+	if _, chainID := tunnel.Session(); chainID != 0 {
+		sr.ChainID = &chainID
+	}
+
+	rpc := &jsonRPCRequest{
+		ID:      jsonrpc.NewID(),
+		JSONRPC: "2.0",
+		Method:  "wc_sessionRequest",
+		Params:  []interface{}{sr},
+	}
+
+	buff, err := json.Marshal(rpc)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal sessionRequest error")
+	}
+
+	err = tunnel.publish(ctx, tunnel.URL.Topic, buff, transport)
 
 	if err != nil {
-		tunnel.Status = Disconnected
 		return err
 	}
 
-	tunnel.Status = Connected
+	for {
+		buff, err := tun4go.ReadContext(ctx, transport)
+
+		if err != nil {
+			return errors.Wrap(err, "read sessionResponse error")
+		}
+
+		buff, err = tunnel.read(buff)
+
+		if err != nil {
+			return err
+		}
+
+		response, err := tunnel.readJSONRPCResponse(buff)
+
+		if err != nil {
+			return err
+		}
+
+		if response.ID != rpc.ID {
+			tunnel.W("skip unexpect msg {@msg}", string(buff))
+			continue
+		}
+
+		return tunnel.handleSessionResponse(response)
+	}
+}
+
+func (tunnel *wcTunnel) handleSessionResponse(response *jsonRPCResponse) error {
+	if response.Error != nil {
+		return errors.Wrap(ErrRejected, "wallet reject session: (%d) %s", response.Error.Code, response.Error.Message)
+	}
+
+	buff, err := json.Marshal(response.Result)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal sessionResponse error")
+	}
+
+	var rsp *sessionResponse
+
+	err = json.Unmarshal(buff, &rsp)
+
+	if err != nil || rsp == nil {
+		return errors.Wrap(ErrFormat, "unmarshal sessionResponse error: %s", string(buff))
+	}
+
+	if !rsp.Approved {
+		return errors.Wrap(ErrRejected, "wallet %s reject session", rsp.PeerID)
+	}
+
+	tunnel.setSession(rsp.PeerID, rsp.PeerMeta, rsp.Accounts, rsp.ChainID)
 
 	return nil
 }
 
-func (tunnel *wcTunnel) handleSessionRequest(request *jsonRPCRequest, transport tun4go.Transport) error {
+func (tunnel *wcTunnel) handleSessionRequest(ctx context.Context, request *jsonRPCRequest, transport tun4go.Transport) error {
 	if len(request.Params) != 1 {
 		return errors.Wrap(ErrFormat, "wc_sessionRequest params number must be 1")
 	}
@@ -399,44 +948,121 @@ func (tunnel *wcTunnel) handleSessionRequest(request *jsonRPCRequest, transport
 
 	err = json.Unmarshal(buff, &sr)
 
-	if err != nil {
-		return errors.Wrap(err, "unmarshal sessionRequest request error")
+	if err != nil || sr == nil || sr.PeerID == "" {
+		return errors.Wrap(ErrFormat, "unmarshal sessionRequest request error: %s", string(buff))
 	}
 
-	approver, ok := transport.(tun4go.Approver)
+	decision, err := tunnel.decide(ctx, sr, buff, transport)
 
-	approved := false
+	if err != nil {
+		return err
+	}
 
-	if !ok {
-		approved = true
-	} else {
-		approved = approver.Approve(buff)
+	if !decision.Approved {
+		if err := tunnel.reject(ctx, sr.PeerID, request.ID, decision.Reason, transport); err != nil {
+			return err
+		}
+
+		return errors.Wrap(ErrRejected, "reject session request of %s: %s", sr.PeerID, decision.Reason)
 	}
 
-	if approved {
-		tunnel.Peer = sr.PeerID
-		tunnel.PeerInfo = sr.PeerMeta
+	accounts, chainID, err := tunnel.decided(decision)
+
+	if err != nil {
+		if err := tunnel.reject(ctx, sr.PeerID, request.ID, "", transport); err != nil {
+			return err
+		}
+
+		return err
 	}
 
-	if err := tunnel.approve(request.ID, approved, transport); err != nil {
+	tunnel.setSession(sr.PeerID, sr.PeerMeta, accounts, chainID)
+
+	if err := tunnel.approve(ctx, request.ID, transport); err != nil {
 		return err
 	}
 
-	if approved {
-		return tunnel.subscribe(tunnel.Self, transport)
+	return tunnel.subscribe(ctx, tunnel.Self, transport)
+}
+
+// decide ask the tunnel approver, or the transport implementing tun4go.Approver,
+// session request is approved if neither exists
+func (tunnel *wcTunnel) decide(ctx context.Context, sr *sessionRequest, buff []byte, transport tun4go.Transport) (*Decision, error) {
+	tunnel.mutex.RLock()
+	approver := tunnel.approver
+	tunnel.mutex.RUnlock()
+
+	if approver != nil {
+		proposal := &SessionProposal{
+			PeerID:   sr.PeerID,
+			PeerMeta: sr.PeerMeta,
+		}
+
+		if sr.ChainID != nil {
+			proposal.ChainID = *sr.ChainID
+		}
+
+		decision, err := approver.Approve(ctx, proposal)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "approve session request of %s error", sr.PeerID)
+		}
+
+		if decision == nil {
+			return Reject(""), nil
+		}
+
+		return decision, nil
+	}
+
+	if legacy, ok := transport.(tun4go.Approver); ok && !legacy.Approve(buff) {
+		return Reject(""), nil
 	}
 
-	return nil
+	return Approve(nil, 0), nil
+}
+
+// decided resolve the approved accounts and chain id of decision
+func (tunnel *wcTunnel) decided(decision *Decision) ([]string, int64, error) {
+	accounts, chainID := tunnel.Session()
+
+	if len(decision.Accounts) != 0 {
+		owned := make(map[string]bool)
+
+		for _, account := range accounts {
+			owned[strings.ToLower(account)] = true
+		}
+
+		for _, account := range decision.Accounts {
+			if !owned[strings.ToLower(account)] {
+				return nil, 0, errors.Wrap(ErrParams, "approved account %s is not tunnel account", account)
+			}
+		}
+
+		accounts = decision.Accounts
+	}
+
+	if decision.ChainID < 0 {
+		return nil, 0, errors.Wrap(ErrParams, "approved chain id %d must be positive", decision.ChainID)
+	}
+
+	if decision.ChainID != 0 {
+		chainID = decision.ChainID
+	}
+
+	return accounts, chainID, nil
 }
 
-func (tunnel *wcTunnel) approve(id int64, approved bool, transport tun4go.Transport) error {
+func (tunnel *wcTunnel) approve(ctx context.Context, id int64, transport tun4go.Transport) error {
+
+	accounts, chainID := tunnel.Session()
 
 	rsp := &sessionResponse{
 		PeerID:   tunnel.Self,
 		PeerMeta: tunnel.SelfInfo,
-		ChainID:  tunnel.ChainID,
-		Approved: approved,
-		Accounts: tunnel.Accounts,
+		ChainID:  chainID,
+		Approved: true,
+		Accounts: accounts,
 	}
 
 	rpc := &jsonRPCResponse{
@@ -451,8 +1077,32 @@ func (tunnel *wcTunnel) approve(id int64, approved bool, transport tun4go.Transp
 		return errors.Wrap(err, "marshal sessionResponse error")
 	}
 
-	return tunnel.doSend(buff, transport)
+	return tunnel.doSend(ctx, buff, transport)
+}
+
+// reject send session rejected error response to dapp peer
+func (tunnel *wcTunnel) reject(ctx context.Context, peer string, id int64, reason string, transport tun4go.Transport) error {
+
+	if reason == "" {
+		reason = "Session Rejected"
+	}
+
+	rpc := &jsonRPCResponse{
+		ID:      id,
+		JSONRPC: "2.0",
+		Error: &jsonRPCError{
+			Code:    -32000,
+			Message: reason,
+		},
+	}
+
+	buff, err := json.Marshal(rpc)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal sessionResponse error")
+	}
 
+	return tunnel.publish(ctx, peer, buff, transport)
 }
 
 func (tunnel *wcTunnel) readJSONRPCRequest(buff []byte) (*jsonRPCRequest, error) {
@@ -460,9 +1110,21 @@ func (tunnel *wcTunnel) readJSONRPCRequest(buff []byte) (*jsonRPCRequest, error)
 
 	err := json.Unmarshal(buff, &request)
 
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshal handshake request error")
+	if err != nil || request == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal json rpc request error: %s", string(buff))
 	}
 
 	return request, nil
 }
+
+func (tunnel *wcTunnel) readJSONRPCResponse(buff []byte) (*jsonRPCResponse, error) {
+	var response *jsonRPCResponse
+
+	err := json.Unmarshal(buff, &response)
+
+	if err != nil || response == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal handshake response error: %s", string(buff))
+	}
+
+	return response, nil
+}
@@ -1,12 +1,13 @@
 package wc
 
 import (
+	"context"
 	"encoding/json"
-	"strings"
+	"fmt"
+	"io"
 	"testing"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/libs4go/errors"
 	"github.com/libs4go/scf4go"
 	_ "github.com/libs4go/scf4go/codec" //
@@ -14,12 +15,16 @@ import (
 	"github.com/libs4go/slf4go"
 	_ "github.com/libs4go/slf4go/backend/console"
 	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/encoding/cbor"
+	"github.com/libs4go/tun4go/provider/wc/bridge"
+	"github.com/libs4go/tun4go/rpc"
+	"github.com/libs4go/tun4go/transport/ws"
 	"github.com/stretchr/testify/require"
 )
 
-var url = "wc:15d9f1ea-ea1f-4e37-ac66-e4b33d7d130d@1?bridge=https%3A%2F%2Fbridge.walletconnect.org&key=88f3350f6f374e65b2a82f8682759342e7471cbcd9f3c4d9af58819c11f73870"
+const account = "0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549"
 
-var transport *websockTransport
+var bridgeServer *bridge.Server
 
 func init() {
 
@@ -37,137 +42,574 @@ func init() {
 		panic(err)
 	}
 
-	transport, err = newWebSockTransport(url)
+	bridgeServer = bridge.New()
+
+	err = bridgeServer.Start("127.0.0.1:0")
 
 	if err != nil {
 		panic(err)
 	}
 }
 
-type websockTransport struct {
-	slf4go.Logger
-	conn *websocket.Conn
-}
-
-func newWebSockTransport(url string) (*websockTransport, error) {
+func newWebSockTransport(url string, options ...ws.Option) (*ws.Transport, error) {
 	u, err := ParseURL(url)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "parse url %s error", url)
 	}
 
-	var bridge = u.Bridge
+	return ws.Dial(u.Bridge, options...)
+}
+
+type pairing struct {
+	dapp            tun4go.Tunnel
+	dappTransport   *ws.Transport
+	wallet          tun4go.Tunnel
+	walletTransport *ws.Transport
+}
+
+func (p *pairing) Close() {
+	p.dappTransport.Close()
+	p.walletTransport.Close()
+}
 
-	if strings.HasPrefix(bridge, "http://") {
-		bridge = "ws" + strings.TrimPrefix(bridge, "http")
-	} else if strings.HasPrefix(bridge, "https://") {
-		bridge = "wss" + strings.TrimPrefix(bridge, "https")
+func pair(t *testing.T) *pairing {
+	return pairWith(t, bridgeServer, tun4go.Params{})
+}
+
+// pairWith pair dapp and wallet through server, params are added to both tunnel params
+func pairWith(t *testing.T, server *bridge.Server, params tun4go.Params, options ...ws.Option) *pairing {
+	dappParams := tun4go.Params{
+		"role":       string(Dapp),
+		"clientinfo": marshal(&ClientInfo{Name: "dapp"}),
+		"bridge":     server.URL(),
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(bridge, nil)
+	for k, v := range params {
+		dappParams[k] = v
+	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "dial to websocket server %s error", u.Bridge)
+	dapp, err := tun4go.New("wc", dappParams)
+
+	require.NoError(t, err)
+
+	handshake := dapp.(Tunnel).HandshakeURL().String()
+
+	dappTransport, err := newWebSockTransport(handshake, options...)
+
+	require.NoError(t, err)
+
+	walletTransport, err := newWebSockTransport(handshake, options...)
+
+	require.NoError(t, err)
+
+	walletParams := tun4go.Params{
+		"clientinfo": marshal(&ClientInfo{Name: "wallet"}),
+		"account":    account,
+		"url":        handshake,
+		"chainId":    "1",
+	}
+
+	for k, v := range params {
+		walletParams[k] = v
+	}
+
+	wallet, err := tun4go.New("wc", walletParams)
+
+	require.NoError(t, err)
+
+	walletErr := make(chan error, 1)
+
+	go func() {
+		walletErr <- wallet.Connect(walletTransport)
+	}()
+
+	require.NoError(t, dapp.Connect(dappTransport))
+
+	require.NoError(t, <-walletErr)
+
+	return &pairing{
+		dapp:            dapp,
+		dappTransport:   dappTransport,
+		wallet:          wallet,
+		walletTransport: walletTransport,
 	}
+}
+
+func TestTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	err := p.dapp.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`), p.dappTransport)
+
+	require.NoError(t, err)
+
+	buff, err := p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
 
-	return &websockTransport{
-		Logger: slf4go.Get("websocket"),
-		conn:   conn,
-	}, nil
+	require.Contains(t, string(buff), "eth_accounts")
 }
 
-func (trans *websockTransport) Read() ([]byte, error) {
-	for {
-		trans.D("Read message from bridge ...")
-		t, message, err := trans.conn.ReadMessage()
+func TestDisconnectTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	err := p.wallet.Disconnect(p.walletTransport)
+
+	require.NoError(t, err)
+
+	_, err = p.dapp.Recv(p.dappTransport)
+
+	require.True(t, errors.Is(err, ErrDisconnected))
+}
+
+func TestDappTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	accounts, chainID := p.dapp.(Tunnel).Session()
+
+	require.Equal(t, []string{account}, accounts)
+	require.Equal(t, int64(1), chainID)
+
+	handshake, err := ParseURL(p.dapp.(Tunnel).HandshakeURL().String())
+
+	require.NoError(t, err)
+	require.Equal(t, bridgeServer.URL(), handshake.Bridge)
+}
+
+func marshal(v interface{}) string {
+	buff, _ := json.Marshal(v)
+
+	return string(buff)
+}
+
+func TestResume(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	buff, err := p.wallet.Context()
+
+	require.NoError(t, err)
+
+	p.walletTransport.Close()
+
+	// wait bridge drop the closed subscriber
+	time.Sleep(50 * time.Millisecond)
+
+	// request sent while wallet offline must be queued by bridge
+	err = p.dapp.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`), p.dappTransport)
+
+	require.NoError(t, err)
+
+	wallet, err := tun4go.FromContext("wc", buff)
+
+	require.NoError(t, err)
+
+	p.walletTransport, err = newWebSockTransport(wallet.(Tunnel).HandshakeURL().String())
+
+	require.NoError(t, err)
+
+	require.NoError(t, wallet.(Tunnel).Resume(p.walletTransport, WithRefresh()))
+
+	buff, err = wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "eth_accounts")
+
+	// dapp side probe wallet
+	go func() {
+		buff, err := wallet.Recv(p.walletTransport)
 
 		if err != nil {
-			return nil, errors.Wrap(err, "read from websocket error")
+			return
 		}
 
-		if t != websocket.TextMessage {
+		request, _ := wallet.(*wcTunnel).readJSONRPCRequest(buff)
+
+		rsp, _ := json.Marshal(&jsonRPCResponse{ID: request.ID, JSONRPC: "2.0", Result: "0x1"})
+
+		wallet.Send(rsp, p.walletTransport)
+	}()
+
+	require.NoError(t, p.dapp.(Tunnel).Resume(p.dappTransport, WithProbe("eth_chainId")))
+
+	require.Error(t, p.dapp.(Tunnel).Resume(p.dappTransport, WithRefresh()))
+}
+
+func TestResumeProbeID(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	walletErr := make(chan error, 1)
 
-			continue
+	// wallet push an undecodable frame, then echo the probe id as string
+	go func() {
+		garbage := fmt.Sprintf(`{"topic":"%s","type":"pub","payload":"garbage"}`, p.wallet.(*wcTunnel).Peer)
+
+		if err := p.walletTransport.Write([]byte(garbage)); err != nil {
+			walletErr <- err
+			return
 		}
 
-		trans.D("Recv msg: {@msg}", string(message))
+		buff, err := p.wallet.Recv(p.walletTransport)
 
-		return message, nil
+		if err != nil {
+			walletErr <- err
+			return
+		}
 
-	}
+		request, err := p.wallet.(*wcTunnel).readJSONRPCRequest(buff)
+
+		if err != nil {
+			walletErr <- err
+			return
+		}
+
+		walletErr <- p.wallet.Send([]byte(fmt.Sprintf(`{"id":"%d","jsonrpc":"2.0","result":"0x1"}`, request.ID)), p.walletTransport)
+	}()
+
+	require.NoError(t, p.dapp.(Tunnel).Resume(p.dappTransport, WithProbe("eth_chainId")))
+	require.NoError(t, <-walletErr)
+
+	// the undecodable frame is left for Recv
+	_, err := p.dapp.Recv(p.dappTransport)
+
+	require.True(t, errors.Is(err, ErrFormat))
 }
 
-func (trans *websockTransport) Write(buff []byte) error {
+func TestUpdate(t *testing.T) {
 
-	trans.D("Send msg: {@msg}", string(buff))
+	defer slf4go.Sync()
 
-	err := trans.conn.WriteMessage(websocket.TextMessage, buff)
+	p := pair(t)
+	defer p.Close()
 
-	if err != nil {
-		return errors.Wrap(err, "websocket send message error")
-	}
+	const other = "0x0000000000000000000000000000000000000001"
 
-	trans.D("Send msg -- success")
+	require.Error(t, p.dapp.(Tunnel).Update([]string{other}, 137, p.dappTransport))
 
-	return nil
+	require.NoError(t, p.wallet.(Tunnel).Update([]string{other}, 137, p.walletTransport))
+
+	accounts, chainID := p.wallet.(Tunnel).Session()
+
+	require.Equal(t, []string{other}, accounts)
+	require.Equal(t, int64(137), chainID)
+
+	buff, err := p.wallet.Context()
+
+	require.NoError(t, err)
+
+	restored, err := fromContext(buff)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{other}, restored.Accounts)
+	require.Equal(t, int64(137), restored.ChainID)
 }
 
-func TestTunnel(t *testing.T) {
+func TestSessionChanged(t *testing.T) {
 
 	defer slf4go.Sync()
 
-	ci := marshal(&clientInfo{})
+	p := pair(t)
+	defer p.Close()
 
-	tunnel, err := tun4go.New("wc", tun4go.Params{
-		"clientinfo": ci,
-		"account":    "0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549",
-		"url":        url,
-		"chainId":    "1",
+	const other = "0x0000000000000000000000000000000000000001"
+
+	var event *SessionEvent
+
+	p.dapp.(Tunnel).OnSessionChanged(func(e *SessionEvent) {
+		event = e
+	})
+
+	require.NoError(t, p.wallet.(Tunnel).Update([]string{other}, 137, p.walletTransport))
+
+	require.NoError(t, p.wallet.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`), p.walletTransport))
+
+	buff, err := p.dapp.Recv(p.dappTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "eth_accounts")
+
+	require.NotNil(t, event)
+	require.Equal(t, []string{other}, event.Accounts)
+
+	accounts, chainID := p.dapp.(Tunnel).Session()
+
+	require.Equal(t, []string{other}, accounts)
+	require.Equal(t, int64(137), chainID)
+
+	malformed, err := json.Marshal(&jsonRPCRequest{
+		ID:      1,
+		JSONRPC: "2.0",
+		Method:  "wc_sessionUpdate",
+		Params:  []interface{}{&sessionUpdate{Approved: true}},
 	})
 
 	require.NoError(t, err)
 
-	err = tunnel.Connect(transport)
+	require.NoError(t, p.wallet.Send(malformed, p.walletTransport))
+
+	_, err = p.dapp.Recv(p.dappTransport)
+
+	require.True(t, errors.Is(err, ErrSessionUpdate))
+}
+
+func TestConnectContext(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	dapp, err := tun4go.New("wc", tun4go.Params{
+		"role":       string(Dapp),
+		"clientinfo": marshal(&ClientInfo{Name: "dapp"}),
+		"bridge":     bridgeServer.URL(),
+	})
 
 	require.NoError(t, err)
 
-	buff, err := tunnel.Recv(transport)
+	transport, err := newWebSockTransport(dapp.(Tunnel).HandshakeURL().String())
 
 	require.NoError(t, err)
 
-	println(string(buff))
+	ct := tun4go.WithContext(transport)
+
+	defer ct.(io.Closer).Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// no wallet pairing, connect must return when deadline exceeded
+	err = dapp.(Tunnel).ConnectContext(ctx, ct)
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, Disconnected, dapp.(Tunnel).Status())
 }
 
-func TestDisconnectTunnel(t *testing.T) {
+func TestEncoding(t *testing.T) {
 
 	defer slf4go.Sync()
 
-	ci := marshal(&clientInfo{})
+	encoding, err := tun4go.GetEncoding(cbor.Name)
 
-	tunnel, err := tun4go.New("wc", tun4go.Params{
-		"clientinfo": ci,
-		"account":    "0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549",
-		"url":        url,
-		"chainId":    "1",
+	require.NoError(t, err)
+
+	server := bridge.New(bridge.WithEncoding(encoding))
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	defer server.Close()
+
+	p := pairWith(t, server, tun4go.Params{"encoding": cbor.Name}, ws.WithBinary())
+	defer p.Close()
+
+	err = p.dapp.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`), p.dappTransport)
+
+	require.NoError(t, err)
+
+	buff, err := p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "eth_accounts")
+
+	_, err = tun4go.New("wc", tun4go.Params{
+		"role":       string(Dapp),
+		"clientinfo": marshal(&ClientInfo{Name: "dapp"}),
+		"bridge":     server.URL(),
+		"encoding":   "unknown",
+	})
+
+	require.True(t, errors.Is(err, ErrParams))
+}
+
+func TestDial(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	dapp, err := tun4go.New("wc", tun4go.Params{
+		"role":       string(Dapp),
+		"clientinfo": marshal(&ClientInfo{Name: "dapp"}),
+		"bridge":     bridgeServer.URL(),
 	})
 
 	require.NoError(t, err)
 
-	err = tunnel.Connect(transport)
+	dappTransport, err := newWebSockTransport(dapp.(Tunnel).HandshakeURL().String())
 
 	require.NoError(t, err)
 
-	println("=============")
+	defer dappTransport.Close()
 
-	time.Sleep(5 * time.Second)
+	dappErr := make(chan error, 1)
 
-	err = tunnel.Disconnect(transport)
+	go func() {
+		dappErr <- dapp.Connect(dappTransport)
+	}()
+
+	wallet, transport, err := tun4go.Dial(dapp.(Tunnel).HandshakeURL().String(), tun4go.WithParams(tun4go.Params{
+		"clientinfo": marshal(&ClientInfo{Name: "wallet"}),
+		"account":    account,
+		"chainId":    "1",
+	}))
 
 	require.NoError(t, err)
+
+	defer transport.(*ws.Transport).Close()
+
+	require.NoError(t, <-dappErr)
+	require.Equal(t, Connected, wallet.(Tunnel).Status())
 }
 
-func marshal(v interface{}) string {
-	buff, _ := json.Marshal(v)
+func TestRecvMessage(t *testing.T) {
 
-	return string(buff)
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	wallet := p.wallet.(Tunnel)
+
+	for _, msg := range []string{
+		`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`,
+		`{"jsonrpc":"2.0","method":"chainChanged","params":["0x1"]}`,
+		`{"id":2,"jsonrpc":"2.0","result":"0x1"}`,
+		`[{"id":3,"jsonrpc":"2.0","method":"eth_chainId","params":[]}]`,
+		`null`,
+		`not json`,
+	} {
+		require.NoError(t, p.dapp.Send([]byte(msg), p.dappTransport))
+	}
+
+	var kinds []rpc.Kind
+
+	for i := 0; i < 4; i++ {
+		msg, err := wallet.RecvMessage(p.walletTransport)
+
+		require.NoError(t, err)
+
+		kinds = append(kinds, msg.Kind)
+	}
+
+	require.Equal(t, []rpc.Kind{rpc.KindRequest, rpc.KindNotification, rpc.KindResponse, rpc.KindBatch}, kinds)
+
+	// malformed msg never panics, Recv passes it as is and RecvMessage reports rpc.ErrFormat
+	buff, err := wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.Equal(t, "null", string(buff))
+
+	_, err = wallet.RecvMessage(p.walletTransport)
+
+	require.True(t, errors.Is(err, rpc.ErrFormat))
+	require.Equal(t, Connected, wallet.Status())
+}
+
+func TestBatchSession(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	server := rpc.New(p.wallet, p.walletTransport, rpc.WithRequestHandler(func(session *rpc.Session, request *rpc.Request) {
+		accounts, chainID := p.wallet.(Tunnel).Session()
+
+		switch request.Method {
+		case "eth_chainId":
+			session.ReplyRequest(request, fmt.Sprintf("0x%x", chainID))
+		case "eth_accounts":
+			session.ReplyRequest(request, accounts)
+		default:
+			session.ReplyRequestError(request, rpc.CodeMethodNotFound, "method not found")
+		}
+	}))
+
+	go server.Run()
+
+	client := rpc.New(p.dapp, p.dappTransport)
+
+	go client.Run()
+
+	var chainID string
+	var accounts []string
+
+	calls := []*rpc.BatchCall{
+		{Method: "eth_chainId", Result: &chainID},
+		{Method: "eth_accounts", Result: &accounts},
+	}
+
+	require.NoError(t, client.CallBatch(calls))
+
+	require.NoError(t, calls[0].Error)
+	require.NoError(t, calls[1].Error)
+	require.Equal(t, "0x1", chainID)
+	require.Equal(t, []string{account}, accounts)
+}
+
+func TestBatchRecv(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	const other = "0x0000000000000000000000000000000000000001"
+
+	update, err := json.Marshal(&jsonRPCRequest{
+		ID:      1,
+		JSONRPC: "2.0",
+		Method:  "wc_sessionUpdate",
+		Params:  []interface{}{&sessionUpdate{Approved: true, Accounts: []string{other}, ChainID: 137}},
+	})
+
+	require.NoError(t, err)
+
+	// dapp answers probes with keepalive set
+	require.NoError(t, p.dapp.(Tunnel).SetKeepalive(&Keepalive{Interval: time.Minute, Timeout: time.Hour}))
+
+	probe := `{"id":2,"jsonrpc":"2.0","method":"wc_sessionPing","params":[]}`
+	request := `{"id":3,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`
+
+	require.NoError(t, p.wallet.Send([]byte(fmt.Sprintf("[%s,%s,%s]", update, probe, request)), p.walletTransport))
+
+	// wc_sessionUpdate and probe are handled by tunnel, the rest is passed to caller as batch
+	buff, err := p.dapp.Recv(p.dappTransport)
+
+	require.NoError(t, err)
+	require.JSONEq(t, fmt.Sprintf("[%s]", request), string(buff))
+
+	accounts, chainID := p.dapp.(Tunnel).Session()
+
+	require.Equal(t, []string{other}, accounts)
+	require.Equal(t, int64(137), chainID)
+
+	// probe responses are sent back as batch
+	buff, err = p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":2,"jsonrpc":"2.0","result":true}]`, string(buff))
+
+	// batch of handled elements only is swallowed
+	require.NoError(t, p.wallet.Send([]byte(fmt.Sprintf("[%s]", update)), p.walletTransport))
+	require.NoError(t, p.wallet.Send([]byte(request), p.walletTransport))
+
+	buff, err = p.dapp.Recv(p.dappTransport)
+
+	require.NoError(t, err)
+	require.JSONEq(t, request, string(buff))
 }
@@ -0,0 +1,145 @@
+package wc2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+
+	"github.com/libs4go/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// envelope types
+const (
+	envelopeType0 byte = 0 // sealed with the topic symmetric key
+	envelopeType1 byte = 1 // sealed with key derived from the attached sender public key
+)
+
+func randomBytes(size int) ([]byte, error) {
+	buff := make([]byte, size)
+
+	if _, err := rand.Read(buff); err != nil {
+		return nil, errors.Wrap(err, "read random bytes error")
+	}
+
+	return buff, nil
+}
+
+// generateKeyPair generate x25519 private key and public key
+func generateKeyPair() (privateKey []byte, publicKey []byte, err error) {
+	privateKey, err = randomBytes(curve25519.ScalarSize)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err = curve25519.X25519(privateKey, curve25519.Basepoint)
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "x25519 public key error")
+	}
+
+	return privateKey, publicKey, nil
+}
+
+// deriveSymKey derive symmetric key with x25519 shared secret expanded by hkdf-sha256
+func deriveSymKey(privateKey []byte, peerPublicKey []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(privateKey, peerPublicKey)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrEnvelope, "x25519 key agreement error")
+	}
+
+	key := make([]byte, chacha20poly1305.KeySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, nil), key); err != nil {
+		return nil, errors.Wrap(err, "hkdf expand error")
+	}
+
+	return key, nil
+}
+
+// topicOf session topic is the sha256 of the session symmetric key
+func topicOf(symKey []byte) string {
+	hash := sha256.Sum256(symKey)
+	return hex.EncodeToString(hash[:])
+}
+
+// seal encrypt data as base64 type-0 envelope, or type-1 envelope if senderPublicKey is not nil
+func seal(data []byte, key []byte, senderPublicKey []byte) (string, error) {
+	aead, err := chacha20poly1305.New(key)
+
+	if err != nil {
+		return "", errors.Wrap(ErrEnvelope, "create chacha20poly1305 error")
+	}
+
+	iv, err := randomBytes(chacha20poly1305.NonceSize)
+
+	if err != nil {
+		return "", err
+	}
+
+	buff := []byte{envelopeType0}
+
+	if senderPublicKey != nil {
+		buff = []byte{envelopeType1}
+		buff = append(buff, senderPublicKey...)
+	}
+
+	buff = append(buff, iv...)
+	buff = aead.Seal(buff, iv, data, nil)
+
+	return base64.StdEncoding.EncodeToString(buff), nil
+}
+
+// open decrypt base64 envelope, type-1 envelope key is resolved by keyOf with the sender public key
+func open(message string, key []byte, keyOf func(senderPublicKey []byte) ([]byte, error)) ([]byte, error) {
+	buff, err := base64.StdEncoding.DecodeString(message)
+
+	if err != nil || len(buff) == 0 {
+		return nil, errors.Wrap(ErrEnvelope, "decode envelope base64 error")
+	}
+
+	envelopeType := buff[0]
+	buff = buff[1:]
+
+	switch envelopeType {
+	case envelopeType0:
+	case envelopeType1:
+		if len(buff) < curve25519.PointSize {
+			return nil, errors.Wrap(ErrEnvelope, "type-1 envelope too short")
+		}
+
+		key, err = keyOf(buff[:curve25519.PointSize])
+
+		if err != nil {
+			return nil, err
+		}
+
+		buff = buff[curve25519.PointSize:]
+	default:
+		return nil, errors.Wrap(ErrEnvelope, "unknown envelope type %d", envelopeType)
+	}
+
+	if len(buff) < chacha20poly1305.NonceSize+chacha20poly1305.Overhead {
+		return nil, errors.Wrap(ErrEnvelope, "envelope too short")
+	}
+
+	aead, err := chacha20poly1305.New(key)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrEnvelope, "create chacha20poly1305 error")
+	}
+
+	data, err := aead.Open(nil, buff[:chacha20poly1305.NonceSize], buff[chacha20poly1305.NonceSize:], nil)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrEnvelope, "envelope authentication failed")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,74 @@
+package wc2
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	key, err := randomBytes(32)
+
+	require.NoError(t, err)
+
+	message, err := seal([]byte("hello"), key, nil)
+
+	require.NoError(t, err)
+
+	buff, err := open(message, key, nil)
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+
+	raw, _ := base64.StdEncoding.DecodeString(message)
+	raw[len(raw)-1] ^= 0xff
+
+	_, err = open(base64.StdEncoding.EncodeToString(raw), key, nil)
+
+	require.True(t, errors.Is(err, ErrEnvelope))
+}
+
+func TestEnvelopeType1(t *testing.T) {
+	senderPrivateKey, senderPublicKey, err := generateKeyPair()
+
+	require.NoError(t, err)
+
+	receiverPrivateKey, receiverPublicKey, err := generateKeyPair()
+
+	require.NoError(t, err)
+
+	key, err := deriveSymKey(senderPrivateKey, receiverPublicKey)
+
+	require.NoError(t, err)
+
+	message, err := seal([]byte("hello"), key, senderPublicKey)
+
+	require.NoError(t, err)
+
+	buff, err := open(message, nil, func(publicKey []byte) ([]byte, error) {
+		require.Equal(t, senderPublicKey, publicKey)
+		return deriveSymKey(receiverPrivateKey, publicKey)
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+	require.Len(t, topicOf(key), 64)
+}
+
+func TestURL(t *testing.T) {
+	uri := "wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2?relay-protocol=irn&symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303&expiryTimestamp=1705000000"
+
+	u, err := ParseURL(uri)
+
+	require.NoError(t, err)
+	require.Equal(t, "2", u.Version)
+	require.Equal(t, RelayProtocol, u.RelayProtocol)
+	require.Equal(t, int64(1705000000), u.ExpiryTimestamp)
+	require.Equal(t, uri, u.String())
+
+	_, err = ParseURL("wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@1?bridge=x&key=y")
+
+	require.True(t, errors.Is(err, ErrURL))
+}
@@ -0,0 +1,20 @@
+package wc2
+
+import "github.com/libs4go/errors"
+
+// ScopeOfAPIError .
+const errVendor = "wc2"
+
+// errors
+var (
+	ErrURL          = errors.New("pairing uri format error", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrParams       = errors.New("tunnel create params error", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrStatus       = errors.New("tunnel status error", errors.WithCode(-3), errors.WithVendor(errVendor))
+	ErrFormat       = errors.New("message format error", errors.WithCode(-4), errors.WithVendor(errVendor))
+	ErrMessage      = errors.New("unexpect message", errors.WithCode(-5), errors.WithVendor(errVendor))
+	ErrEnvelope     = errors.New("envelope seal or open error", errors.WithCode(-6), errors.WithVendor(errVendor))
+	ErrRejected     = errors.New("session rejected by peer", errors.WithCode(-7), errors.WithVendor(errVendor))
+	ErrDisconnected = errors.New("session deleted by peer", errors.WithCode(-8), errors.WithVendor(errVendor))
+	ErrExpired      = errors.New("session expired", errors.WithCode(-9), errors.WithVendor(errVendor))
+	ErrNamespaces   = errors.New("namespaces format error", errors.WithCode(-10), errors.WithVendor(errVendor))
+)
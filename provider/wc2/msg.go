@@ -0,0 +1,151 @@
+package wc2
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// relayMessage frame exchanged between tunnel and relay transport
+type relayMessage struct {
+	Type    string `json:"type"`              // sub or pub
+	Topic   string `json:"topic"`             // relay topic
+	Message string `json:"message,omitempty"` // base64 envelope
+	Tag     int    `json:"tag,omitempty"`     // publish tag
+	TTL     int64  `json:"ttl,omitempty"`     // publish ttl in seconds
+}
+
+// methodSpec relay publish tag and ttl of sign api method
+type methodSpec struct {
+	Tag         int
+	ResponseTag int
+	TTL         int64
+}
+
+// sign api methods
+const (
+	methodSessionPropose = "wc_sessionPropose"
+	methodSessionSettle  = "wc_sessionSettle"
+	methodSessionUpdate  = "wc_sessionUpdate"
+	methodSessionExtend  = "wc_sessionExtend"
+	methodSessionRequest = "wc_sessionRequest"
+	methodSessionEvent   = "wc_sessionEvent"
+	methodSessionDelete  = "wc_sessionDelete"
+	methodSessionPing    = "wc_sessionPing"
+)
+
+var methodSpecs = map[string]*methodSpec{
+	methodSessionPropose: {Tag: 1100, ResponseTag: 1101, TTL: 300},
+	methodSessionSettle:  {Tag: 1102, ResponseTag: 1103, TTL: 300},
+	methodSessionUpdate:  {Tag: 1104, ResponseTag: 1105, TTL: 86400},
+	methodSessionExtend:  {Tag: 1106, ResponseTag: 1107, TTL: 86400},
+	methodSessionRequest: {Tag: 1108, ResponseTag: 1109, TTL: 300},
+	methodSessionEvent:   {Tag: 1110, ResponseTag: 1111, TTL: 300},
+	methodSessionDelete:  {Tag: 1112, ResponseTag: 1113, TTL: 86400},
+	methodSessionPing:    {Tag: 1114, ResponseTag: 1115, TTL: 30},
+}
+
+// sessionTTL session expiry duration, extend resets expiry to now + sessionTTL
+const sessionTTL = 7 * 24 * time.Hour
+
+// sign api error codes
+const (
+	codeUserRejected            = 5000
+	codeUnsupportedChains       = 5100
+	codeUnsupportedMethods      = 5101
+	codeUnsupportedEvents       = 5102
+	codeUnsupportedNamespaces   = 5104
+	codeUserDisconnected        = 6000
+	codeInvalidExtendRequest    = 5201
+	codeInvalidSessionRequest   = 1001
+	messageUserDisconnected     = "User disconnected."
+	messageUserRejected         = "User rejected."
+	messageUnsupportedChains    = "Unsupported chains."
+	messageUnsupportedMethods   = "Unsupported methods."
+	messageUnsupportedEvents    = "Unsupported events."
+	messageUnsupportedNamespace = "Unsupported namespace key."
+)
+
+// jsonRPCMessage json rpc request or response
+type jsonRPCMessage struct {
+	ID      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Metadata peer client metadata
+type Metadata struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Icons       []string `json:"icons"`
+}
+
+type relayProtocol struct {
+	Protocol string `json:"protocol"`
+}
+
+type participant struct {
+	PublicKey string    `json:"publicKey"`
+	Metadata  *Metadata `json:"metadata"`
+}
+
+type sessionProposal struct {
+	Relays             []*relayProtocol              `json:"relays"`
+	RequiredNamespaces map[string]*ProposalNamespace `json:"requiredNamespaces"`
+	Proposer           *participant                  `json:"proposer"`
+}
+
+type proposalResponse struct {
+	Relay              *relayProtocol `json:"relay"`
+	ResponderPublicKey string         `json:"responderPublicKey"`
+}
+
+type sessionSettle struct {
+	Relay      *relayProtocol        `json:"relay"`
+	Namespaces map[string]*Namespace `json:"namespaces"`
+	Controller *participant          `json:"controller"`
+	Expiry     int64                 `json:"expiry"`
+}
+
+type sessionUpdate struct {
+	Namespaces map[string]*Namespace `json:"namespaces"`
+}
+
+type sessionExtend struct {
+	Expiry int64 `json:"expiry"`
+}
+
+type sessionDelete struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type sessionRequest struct {
+	Request *sessionRequestBody `json:"request"`
+	ChainID string              `json:"chainId"`
+}
+
+type sessionRequestBody struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// appMessage json rpc message exchanged with application through Send and Recv,
+// chainId is the CAIP-2 chain a request targets
+type appMessage struct {
+	ID      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ChainID string          `json:"chainId,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
@@ -0,0 +1,69 @@
+package wc2
+
+import (
+	"sort"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go/caip"
+)
+
+// ProposalNamespace chains, methods and events the dapp requires in namespace
+type ProposalNamespace = caip.ProposalNamespace
+
+// Namespace accounts, methods and events the wallet approved in namespace
+type Namespace = caip.SessionNamespace
+
+// validateProposalNamespaces check chains are CAIP-2 chain ids of namespace key
+func validateProposalNamespaces(namespaces map[string]*ProposalNamespace) error {
+	if err := caip.ValidateProposalNamespaces(namespaces); err != nil {
+		return errors.Wrap(ErrNamespaces, "%s", err)
+	}
+
+	return nil
+}
+
+// validateNamespaces check accounts are CAIP-10 account ids of namespace key
+func validateNamespaces(namespaces map[string]*Namespace) error {
+	if err := caip.ValidateSessionNamespaces(namespaces); err != nil {
+		return errors.Wrap(ErrNamespaces, "%s", err)
+	}
+
+	return nil
+}
+
+// satisfy check approved namespaces cover required namespaces, returns the sign api reject error if not
+func satisfy(required map[string]*ProposalNamespace, approved map[string]*Namespace) *jsonRPCError {
+	err := caip.Satisfy(required, approved)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, caip.ErrUnsupportedChains):
+		return &jsonRPCError{Code: codeUnsupportedChains, Message: messageUnsupportedChains}
+	case errors.Is(err, caip.ErrUnsupportedMethods):
+		return &jsonRPCError{Code: codeUnsupportedMethods, Message: messageUnsupportedMethods}
+	case errors.Is(err, caip.ErrUnsupportedEvents):
+		return &jsonRPCError{Code: codeUnsupportedEvents, Message: messageUnsupportedEvents}
+	default:
+		return &jsonRPCError{Code: codeUnsupportedNamespaces, Message: messageUnsupportedNamespace}
+	}
+}
+
+// defaultChain the first chain of the first namespace in key order
+func defaultChain(namespaces map[string]*Namespace) string {
+	var keys []string
+
+	for key := range namespaces {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if chains := namespaces[key].ChainIDs(); len(chains) != 0 {
+			return chains[0]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,81 @@
+package wc2
+
+import (
+	"encoding/json"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+)
+
+// Options wc2 tunnel create options
+type Options struct {
+	Role               Role                          // tunnel role, default is Wallet
+	URL                string                        // wallet: pairing uri provide by dapp
+	Namespaces         map[string]*Namespace         // wallet: approved session namespaces
+	RequiredNamespaces map[string]*ProposalNamespace // dapp: required namespaces of session proposal
+	Metadata           *Metadata                     // self client metadata
+}
+
+// Validate check options, the returned error names the offending field
+func (options *Options) Validate() error {
+	switch options.Role {
+	case "", Wallet:
+		if options.URL == "" {
+			return errors.Wrap(ErrParams, "Options.URL is required by wallet")
+		}
+
+		if len(options.Namespaces) == 0 {
+			return errors.Wrap(ErrParams, "Options.Namespaces is required by wallet")
+		}
+
+		if err := validateNamespaces(options.Namespaces); err != nil {
+			return errors.Wrap(ErrParams, "Options.Namespaces invalid: %s", err)
+		}
+	case Dapp:
+		if err := validateProposalNamespaces(options.RequiredNamespaces); err != nil {
+			return errors.Wrap(ErrParams, "Options.RequiredNamespaces invalid: %s", err)
+		}
+	default:
+		return errors.Wrap(ErrParams, "Options.Role %s unknown", options.Role)
+	}
+
+	if options.Metadata == nil {
+		return errors.Wrap(ErrParams, "Options.Metadata is required")
+	}
+
+	return nil
+}
+
+// New create wc2 tunnel with options
+func New(options *Options) (Tunnel, error) {
+	return newTunnel(options)
+}
+
+// optionsFromParams convert tun4go.Params to Options, the keys are:
+// role, url, namespaces (json), requiredNamespaces (json), metadata (json)
+func optionsFromParams(params tun4go.Params) (*Options, error) {
+	options := &Options{
+		Role: Role(params["role"]),
+		URL:  params["url"],
+	}
+
+	if buff, ok := params["namespaces"]; ok {
+		if err := json.Unmarshal([]byte(buff), &options.Namespaces); err != nil {
+			return nil, errors.Wrap(ErrParams, "namespaces param %s unmarshal error", buff)
+		}
+	}
+
+	if buff, ok := params["requiredNamespaces"]; ok {
+		if err := json.Unmarshal([]byte(buff), &options.RequiredNamespaces); err != nil {
+			return nil, errors.Wrap(ErrParams, "requiredNamespaces param %s unmarshal error", buff)
+		}
+	}
+
+	if buff, ok := params["metadata"]; ok {
+		if err := json.Unmarshal([]byte(buff), &options.Metadata); err != nil {
+			return nil, errors.Wrap(ErrParams, "metadata param %s unmarshal error", buff)
+		}
+	}
+
+	return options, nil
+}
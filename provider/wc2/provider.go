@@ -0,0 +1,60 @@
+package wc2
+
+import (
+	"sync"
+
+	"github.com/libs4go/tun4go"
+)
+
+type wc2Provider struct {
+	sync.Mutex
+	relayURL string
+	opts     []RelayOption
+}
+
+func newWC2Provider() *wc2Provider {
+	return &wc2Provider{
+		relayURL: DefaultRelayURL,
+	}
+}
+
+func (provider *wc2Provider) Name() string {
+	return "wc2"
+}
+
+func (provider *wc2Provider) FromContext(context []byte) (tun4go.Tunnel, error) {
+	return fromContext(context)
+}
+
+func (provider *wc2Provider) New(params tun4go.Params) (tun4go.Tunnel, error) {
+	return newWC2Tunnel(params)
+}
+
+// NewTransport dial to the relay set by SetRelay for pairing uri
+func (provider *wc2Provider) NewTransport(uri string) (tun4go.Transport, error) {
+	if _, err := ParseURL(uri); err != nil {
+		return nil, err
+	}
+
+	provider.Lock()
+	relayURL, opts := provider.relayURL, provider.opts
+	provider.Unlock()
+
+	return DialRelay(relayURL, opts...)
+}
+
+var defaultProvider = newWC2Provider()
+
+// SetRelay set the relay server and dial options of transports opened by tun4go.Dial
+// for wc:<topic>@2 uris, default is DefaultRelayURL without options
+func SetRelay(relayURL string, opts ...RelayOption) {
+	defaultProvider.Lock()
+	defer defaultProvider.Unlock()
+
+	defaultProvider.relayURL = relayURL
+	defaultProvider.opts = opts
+}
+
+func init() {
+	tun4go.RegisterProvider(defaultProvider, "wc@2")
+}
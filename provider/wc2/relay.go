@@ -0,0 +1,283 @@
+package wc2
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"sync"
+	"time"
+
+	neturl "net/url"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go/provider/wc2/relay"
+	"github.com/libs4go/tun4go/transport/ws"
+)
+
+// DefaultRelayURL public wallet connect v2 relay
+const DefaultRelayURL = "wss://relay.walletconnect.com"
+
+type relayOptions struct {
+	projectID string
+	authKey   ed25519.PrivateKey
+	authTTL   time.Duration
+	wsOptions []ws.Option
+}
+
+// RelayOption relay transport dial option
+type RelayOption func(options *relayOptions)
+
+// WithProjectID set the project id query param required by the public relay
+func WithProjectID(projectID string) RelayOption {
+	return func(options *relayOptions) {
+		options.projectID = projectID
+	}
+}
+
+// WithAuthKey set the ed25519 key signing the auth jwt, default is a random key
+func WithAuthKey(key ed25519.PrivateKey) RelayOption {
+	return func(options *relayOptions) {
+		options.authKey = key
+	}
+}
+
+// WithAuthTTL set the auth jwt lifetime, default is 24 hours
+func WithAuthTTL(ttl time.Duration) RelayOption {
+	return func(options *relayOptions) {
+		options.authTTL = ttl
+	}
+}
+
+// WithWebsocket set the underlying websocket dial options
+func WithWebsocket(opts ...ws.Option) RelayOption {
+	return func(options *relayOptions) {
+		options.wsOptions = opts
+	}
+}
+
+// RelayTransport tun4go.ContextTransport speaking relay json rpc, the tunnel sub and pub frames are
+// sent as irn_subscribe and irn_publish, irn_subscription msg are acknowledged and read as pub frames
+type RelayTransport struct {
+	logger        slf4go.Logger
+	mutex         sync.Mutex
+	conn          *ws.Transport
+	pending       map[int64]*relay.Message // request id to request
+	subscriptions map[string]string        // topic to subscription id
+}
+
+// DialRelay dial to relay server with auth jwt signed by ed25519 did:key
+func DialRelay(relayURL string, opts ...RelayOption) (*RelayTransport, error) {
+	options := &relayOptions{
+		authTTL: 24 * time.Hour,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.authKey == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "generate auth key error")
+		}
+
+		options.authKey = key
+	}
+
+	u, err := neturl.Parse(relayURL)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrParams, "relay url %s parse error", relayURL)
+	}
+
+	token, err := relay.SignJWT(options.authKey, relayURL, options.authTTL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+
+	query.Set("auth", token)
+
+	if options.projectID != "" {
+		query.Set("projectId", options.projectID)
+	}
+
+	u.RawQuery = query.Encode()
+
+	conn, err := ws.Dial(u.String(), options.wsOptions...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelayTransport{
+		logger:        slf4go.Get("wc2-relay-client"),
+		conn:          conn,
+		pending:       make(map[int64]*relay.Message),
+		subscriptions: make(map[string]string),
+	}, nil
+}
+
+// Subscription get the subscription id of topic
+func (transport *RelayTransport) Subscription(topic string) (string, bool) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	id, ok := transport.subscriptions[topic]
+
+	return id, ok
+}
+
+// Read read next irn_subscription msg as pub frame, relay responses are consumed
+func (transport *RelayTransport) Read() ([]byte, error) {
+	return transport.ReadContext(context.Background())
+}
+
+// ReadContext context aware Read, returns ctx.Err() when ctx done
+func (transport *RelayTransport) ReadContext(ctx context.Context) ([]byte, error) {
+	for {
+		buff, err := transport.conn.ReadContext(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var msg *relay.Message
+
+		if err := json.Unmarshal(buff, &msg); err != nil || msg == nil {
+			transport.logger.W("skip invalid relay msg {@msg}", string(buff))
+			continue
+		}
+
+		if msg.Method == "" {
+			transport.handleResponse(msg)
+			continue
+		}
+
+		if msg.Method != relay.MethodSubscription {
+			transport.logger.W("skip unknown relay method {@method}", msg.Method)
+			continue
+		}
+
+		var params *relay.SubscriptionParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil || params.Data == nil {
+			transport.logger.W("skip invalid relay subscription {@msg}", string(buff))
+			continue
+		}
+
+		ack, err := relay.NewResponse(msg.ID, true, nil)
+
+		if err := transport.send(ctx, ack, err); err != nil {
+			return nil, err
+		}
+
+		buff, err = json.Marshal(&relayMessage{
+			Type:    "pub",
+			Topic:   params.Data.Topic,
+			Message: params.Data.Message,
+			Tag:     params.Data.Tag,
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal relayMessage error")
+		}
+
+		return buff, nil
+	}
+}
+
+func (transport *RelayTransport) handleResponse(msg *relay.Message) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	request, ok := transport.pending[msg.ID]
+
+	if !ok {
+		return
+	}
+
+	delete(transport.pending, msg.ID)
+
+	if msg.Error != nil {
+		transport.logger.W("relay {@method} error ({@code}) {@message}", request.Method, msg.Error.Code, msg.Error.Message)
+		return
+	}
+
+	if request.Method == relay.MethodSubscribe {
+		var params *relay.SubscribeParams
+		var id string
+
+		if json.Unmarshal(request.Params, &params) == nil && json.Unmarshal(msg.Result, &id) == nil {
+			transport.subscriptions[params.Topic] = id
+		}
+	}
+}
+
+// Write send sub frame as irn_subscribe and pub frame as irn_publish
+func (transport *RelayTransport) Write(buff []byte) error {
+	return transport.WriteContext(context.Background(), buff)
+}
+
+// WriteContext context aware Write, ctx deadline is used as write deadline
+func (transport *RelayTransport) WriteContext(ctx context.Context, buff []byte) error {
+	var frame *relayMessage
+
+	if err := json.Unmarshal(buff, &frame); err != nil || frame == nil {
+		return errors.Wrap(ErrFormat, "unmarshal relayMessage error %s", string(buff))
+	}
+
+	switch frame.Type {
+	case "sub":
+		request, err := relay.NewRequest(relay.MethodSubscribe, &relay.SubscribeParams{Topic: frame.Topic})
+
+		return transport.send(ctx, request, err)
+	case "pub":
+		ttl := frame.TTL
+
+		if ttl == 0 {
+			ttl = methodSpecs[methodSessionRequest].TTL
+		}
+
+		request, err := relay.NewRequest(relay.MethodPublish, &relay.PublishParams{
+			Topic:   frame.Topic,
+			Message: frame.Message,
+			TTL:     ttl,
+			Tag:     frame.Tag,
+		})
+
+		return transport.send(ctx, request, err)
+	default:
+		return errors.Wrap(ErrFormat, "unknown relayMessage type %s", frame.Type)
+	}
+}
+
+func (transport *RelayTransport) send(ctx context.Context, msg *relay.Message, err error) error {
+	if err != nil {
+		return errors.Wrap(err, "create relay msg error")
+	}
+
+	buff, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal relay msg error")
+	}
+
+	if msg.Method != "" {
+		transport.mutex.Lock()
+		transport.pending[msg.ID] = msg
+		transport.mutex.Unlock()
+	}
+
+	return transport.conn.WriteContext(ctx, buff)
+}
+
+// Close close the relay connection, later calls are no-op
+func (transport *RelayTransport) Close() error {
+	return transport.conn.Close()
+}
@@ -0,0 +1,199 @@
+package relay
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/libs4go/errors"
+)
+
+// did:key multicodec prefix of ed25519 public key
+var ed25519Multicodec = []byte{0xed, 0x01}
+
+const didKeyPrefix = "did:key:z"
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Claims relay client auth jwt claims
+type Claims struct {
+	Issuer    string `json:"iss"` // did:key of client ed25519 public key
+	Subject   string `json:"sub"` // random client nonce
+	Audience  string `json:"aud"` // relay url
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+func base58Encode(buff []byte) string {
+	n := new(big.Int).SetBytes(buff)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range buff {
+		if b != 0 {
+			break
+		}
+
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return string(out)
+}
+
+func base58Decode(str string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+
+	for _, c := range str {
+		index := strings.IndexRune(base58Alphabet, c)
+
+		if index < 0 {
+			return nil, errors.Wrap(ErrDIDKey, "invalid base58 char %c", c)
+		}
+
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(index)))
+	}
+
+	var zeros int
+
+	for zeros < len(str) && str[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// EncodeDIDKey encode ed25519 public key as did:key
+func EncodeDIDKey(publicKey ed25519.PublicKey) string {
+	return didKeyPrefix + base58Encode(append(append([]byte{}, ed25519Multicodec...), publicKey...))
+}
+
+// DecodeDIDKey decode ed25519 public key from did:key
+func DecodeDIDKey(did string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(did, didKeyPrefix) {
+		return nil, errors.Wrap(ErrDIDKey, "%s is not base58btc did:key", did)
+	}
+
+	buff, err := base58Decode(strings.TrimPrefix(did, didKeyPrefix))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buff) != len(ed25519Multicodec)+ed25519.PublicKeySize || buff[0] != ed25519Multicodec[0] || buff[1] != ed25519Multicodec[1] {
+		return nil, errors.Wrap(ErrDIDKey, "%s is not ed25519 did:key", did)
+	}
+
+	return ed25519.PublicKey(buff[len(ed25519Multicodec):]), nil
+}
+
+// SignJWT sign relay client auth jwt, the issuer is the did:key of privateKey
+func SignJWT(privateKey ed25519.PrivateKey, audience string, ttl time.Duration) (string, error) {
+	nonce := make([]byte, 32)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "read random bytes error")
+	}
+
+	now := time.Now()
+
+	claims := &Claims{
+		Issuer:    EncodeDIDKey(privateKey.Public().(ed25519.PublicKey)),
+		Subject:   hex.EncodeToString(nonce),
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	header, err := json.Marshal(&jwtHeader{Algorithm: "EdDSA", Type: "JWT"})
+
+	if err != nil {
+		return "", errors.Wrap(err, "marshal jwt header error")
+	}
+
+	payload, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", errors.Wrap(err, "marshal jwt claims error")
+	}
+
+	data := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature := ed25519.Sign(privateKey, []byte(data))
+
+	return data + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyJWT verify relay client auth jwt signed by the issuer did:key,
+// the audience is not checked if audience is empty
+func VerifyJWT(token string, audience string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrJWT, "jwt expect 3 parts")
+	}
+
+	var header *jwtHeader
+
+	if buff, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(buff, &header) != nil || header == nil {
+		return nil, errors.Wrap(ErrJWT, "decode jwt header error")
+	}
+
+	if header.Algorithm != "EdDSA" {
+		return nil, errors.Wrap(ErrJWT, "unsupported jwt alg %s", header.Algorithm)
+	}
+
+	var claims *Claims
+
+	if buff, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(buff, &claims) != nil || claims == nil {
+		return nil, errors.Wrap(ErrJWT, "decode jwt claims error")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, errors.Wrap(ErrJWT, "decode jwt signature error")
+	}
+
+	publicKey, err := DecodeDIDKey(claims.Issuer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errors.Wrap(ErrJWT, "jwt signature mismatch")
+	}
+
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, errors.Wrap(ErrJWT, "jwt expired at %d", claims.ExpiresAt)
+	}
+
+	if audience != "" && claims.Audience != audience {
+		return nil, errors.Wrap(ErrJWT, "jwt audience %s mismatch", claims.Audience)
+	}
+
+	return claims, nil
+}
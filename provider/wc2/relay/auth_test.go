@@ -0,0 +1,75 @@
+package relay
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+
+	require.NoError(t, err)
+
+	did := EncodeDIDKey(publicKey)
+
+	require.True(t, strings.HasPrefix(did, "did:key:z6Mk"))
+
+	decoded, err := DecodeDIDKey(did)
+
+	require.NoError(t, err)
+	require.Equal(t, publicKey, decoded)
+
+	_, err = DecodeDIDKey("did:key:zQ3sh")
+
+	require.True(t, errors.Is(err, ErrDIDKey))
+
+	buff, err := base58Decode(base58Encode([]byte{0, 0, 1, 2}))
+
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 1, 2}, buff)
+}
+
+func TestJWT(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	require.NoError(t, err)
+
+	token, err := SignJWT(privateKey, "wss://relay.example.com", time.Hour)
+
+	require.NoError(t, err)
+
+	claims, err := VerifyJWT(token, "wss://relay.example.com")
+
+	require.NoError(t, err)
+	require.Equal(t, EncodeDIDKey(publicKey), claims.Issuer)
+
+	_, err = VerifyJWT(token, "wss://other.example.com")
+
+	require.True(t, errors.Is(err, ErrJWT))
+
+	// flip a signature char carrying no padding bits so the decoded signature always changes
+	tampered := []byte(token)
+	if tampered[len(tampered)-10] == 'A' {
+		tampered[len(tampered)-10] = 'B'
+	} else {
+		tampered[len(tampered)-10] = 'A'
+	}
+
+	_, err = VerifyJWT(string(tampered), "")
+
+	require.True(t, errors.Is(err, ErrJWT))
+
+	token, err = SignJWT(privateKey, "", -time.Minute)
+
+	require.NoError(t, err)
+
+	_, err = VerifyJWT(token, "")
+
+	require.True(t, errors.Is(err, ErrJWT))
+}
@@ -0,0 +1,12 @@
+package relay
+
+import "github.com/libs4go/errors"
+
+// ScopeOfAPIError .
+const errVendor = "relay"
+
+// errors
+var (
+	ErrJWT    = errors.New("relay auth jwt error", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrDIDKey = errors.New("did:key format error", errors.WithCode(-2), errors.WithVendor(errVendor))
+)
@@ -0,0 +1,117 @@
+package relay
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/libs4go/tun4go/internal/jsonrpc"
+)
+
+// relay json rpc methods
+const (
+	MethodSubscribe    = "irn_subscribe"
+	MethodUnsubscribe  = "irn_unsubscribe"
+	MethodPublish      = "irn_publish"
+	MethodSubscription = "irn_subscription"
+)
+
+// publish ttl bounds
+const (
+	MinTTL = 10 * time.Second
+	MaxTTL = 30 * 24 * time.Hour
+)
+
+// json rpc error codes
+const (
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeUnauthorized   = 3000
+)
+
+// Message relay json rpc request or response
+type Message struct {
+	ID      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error relay json rpc error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SubscribeParams irn_subscribe params
+type SubscribeParams struct {
+	Topic string `json:"topic"`
+}
+
+// UnsubscribeParams irn_unsubscribe params
+type UnsubscribeParams struct {
+	Topic string `json:"topic"`
+	ID    string `json:"id"`
+}
+
+// PublishParams irn_publish params
+type PublishParams struct {
+	Topic   string `json:"topic"`
+	Message string `json:"message"`
+	TTL     int64  `json:"ttl"`
+	Tag     int    `json:"tag"`
+	Prompt  bool   `json:"prompt,omitempty"`
+}
+
+// SubscriptionParams irn_subscription params
+type SubscriptionParams struct {
+	ID   string            `json:"id"`
+	Data *SubscriptionData `json:"data"`
+}
+
+// SubscriptionData published msg delivered by irn_subscription
+type SubscriptionData struct {
+	Topic       string `json:"topic"`
+	Message     string `json:"message"`
+	PublishedAt int64  `json:"publishedAt"`
+	Tag         int    `json:"tag"`
+}
+
+// NewRequest create relay json rpc request with random id
+func NewRequest(method string, params interface{}) (*Message, error) {
+	buff, err := json.Marshal(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		ID:      jsonrpc.NewID(),
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  buff,
+	}, nil
+}
+
+// NewResponse create relay json rpc response, rpcErr is sent instead of result if not nil
+func NewResponse(id int64, result interface{}, rpcErr *Error) (*Message, error) {
+	msg := &Message{
+		ID:      id,
+		JSONRPC: "2.0",
+		Error:   rpcErr,
+	}
+
+	if rpcErr == nil {
+		buff, err := json.Marshal(result)
+
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Result = buff
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,470 @@
+// Package relay implement wallet connect v2 relay client auth and an embeddable in memory relay server
+package relay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+)
+
+// mailbox msg kept until acknowledged by a subscriber other than the publisher or expired
+type mailboxMessage struct {
+	data      *SubscriptionData
+	publisher *wsClient
+	expired   time.Time
+	delivered map[*wsClient]bool
+	acked     bool
+}
+
+type wsClient struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	subs      map[string]string         // topic to subscription id
+	pending   map[int64]*mailboxMessage // irn_subscription request id to msg
+}
+
+func (client *wsClient) write(msg *Message) error {
+	buff, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal relay msg error")
+	}
+
+	client.writeLock.Lock()
+	defer client.writeLock.Unlock()
+
+	return client.conn.WriteMessage(websocket.TextMessage, buff)
+}
+
+type options struct {
+	auth         bool
+	audience     string
+	mailboxLimit int
+}
+
+// Option relay server option
+type Option func(options *options)
+
+// WithAuth require client auth jwt, the aud claim is checked if audience is not empty.
+// Without this option the auth jwt is only verified when present
+func WithAuth(audience string) Option {
+	return func(options *options) {
+		options.auth = true
+		options.audience = audience
+	}
+}
+
+// WithMailboxLimit set max kept msg number per topic, the oldest msg is dropped when overflow
+func WithMailboxLimit(limit int) Option {
+	return func(options *options) {
+		options.mailboxLimit = limit
+	}
+}
+
+// Server in memory wallet connect v2 relay server
+type Server struct {
+	logger   slf4go.Logger
+	mutex    sync.Mutex
+	options  *options
+	upgrader websocket.Upgrader
+	subs     map[string]map[*wsClient]bool
+	mailbox  map[string][]*mailboxMessage
+	listener net.Listener
+	server   *http.Server
+	clients  map[*wsClient]bool // connected websocket clients, closed by Close
+	closed   bool
+	wg       sync.WaitGroup // running websocket handlers
+}
+
+// New create relay server
+func New(opts ...Option) *Server {
+	options := &options{
+		mailboxLimit: 1024,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Server{
+		logger:  slf4go.Get("wc2-relay"),
+		options: options,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		subs:    make(map[string]map[*wsClient]bool),
+		mailbox: make(map[string][]*mailboxMessage),
+		clients: make(map[*wsClient]bool),
+	}
+}
+
+// Start listen on addr and serve in background, use "127.0.0.1:0" to pick a random port
+func (server *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return errors.Wrap(err, "listen on %s error", addr)
+	}
+
+	server.listener = listener
+	server.server = &http.Server{Handler: server}
+
+	go server.server.Serve(listener)
+
+	return nil
+}
+
+// URL get the relay url of started server, returns "" before Start
+func (server *Server) URL() string {
+	if server.listener == nil {
+		return ""
+	}
+
+	return "ws://" + server.listener.Addr().String()
+}
+
+// Close stop the started server, close connected websocket clients and wait their handlers exit
+func (server *Server) Close() error {
+	if server.server == nil {
+		return nil
+	}
+
+	err := server.server.Close()
+
+	server.mutex.Lock()
+
+	server.closed = true
+
+	for client := range server.clients {
+		client.conn.Close()
+	}
+
+	server.mutex.Unlock()
+
+	server.wg.Wait()
+
+	return err
+}
+
+// ServeHTTP implement http.Handler
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	auth := r.URL.Query().Get("auth")
+
+	if auth != "" || server.options.auth {
+		if _, err := VerifyJWT(auth, server.options.audience); err != nil {
+			server.logger.W("reject client auth {@err}", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	conn, err := server.upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		server.logger.W("upgrade websocket error {@err}", err)
+		return
+	}
+
+	client := &wsClient{
+		conn:    conn,
+		subs:    make(map[string]string),
+		pending: make(map[int64]*mailboxMessage),
+	}
+
+	server.mutex.Lock()
+
+	if server.closed {
+		server.mutex.Unlock()
+		conn.Close()
+		return
+	}
+
+	server.clients[client] = true
+	server.wg.Add(1)
+
+	server.mutex.Unlock()
+
+	defer func() {
+		server.disconnect(client)
+		conn.Close()
+
+		server.mutex.Lock()
+		delete(server.clients, client)
+		server.mutex.Unlock()
+
+		server.wg.Done()
+	}()
+
+	for {
+		_, buff, err := conn.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		var msg *Message
+
+		if err := json.Unmarshal(buff, &msg); err != nil || msg == nil {
+			server.logger.W("skip invalid msg {@msg}", string(buff))
+			continue
+		}
+
+		if msg.Method == "" {
+			server.ack(client, msg)
+			continue
+		}
+
+		result, rpcErr := server.dispatch(client, msg)
+
+		rsp, err := NewResponse(msg.ID, result, rpcErr)
+
+		if err != nil {
+			server.logger.W("create response error {@err}", err)
+			continue
+		}
+
+		if err := client.write(rsp); err != nil {
+			return
+		}
+
+		if msg.Method == MethodSubscribe && rpcErr == nil {
+			var params *SubscribeParams
+			json.Unmarshal(msg.Params, &params)
+			server.flush(client, params.Topic)
+		}
+	}
+}
+
+func (server *Server) dispatch(client *wsClient, msg *Message) (interface{}, *Error) {
+	switch msg.Method {
+	case MethodSubscribe:
+		var params *SubscribeParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil || params.Topic == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "expect topic"}
+		}
+
+		return server.subscribe(client, params.Topic), nil
+	case MethodUnsubscribe:
+		var params *UnsubscribeParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil || params.Topic == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "expect topic"}
+		}
+
+		server.unsubscribe(client, params.Topic)
+
+		return true, nil
+	case MethodPublish:
+		var params *PublishParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil || params.Topic == "" || params.Message == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "expect topic and message"}
+		}
+
+		ttl := time.Duration(params.TTL) * time.Second
+
+		if ttl < MinTTL || ttl > MaxTTL {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("ttl %d out of range", params.TTL)}
+		}
+
+		server.publish(client, params, ttl)
+
+		return true, nil
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not found", msg.Method)}
+	}
+}
+
+func (server *Server) subscribe(client *wsClient, topic string) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if id, ok := client.subs[topic]; ok {
+		return id
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%p:%d", topic, client, time.Now().UnixNano())))
+	id := hex.EncodeToString(hash[:])
+
+	client.subs[topic] = id
+
+	clients, ok := server.subs[topic]
+
+	if !ok {
+		clients = make(map[*wsClient]bool)
+		server.subs[topic] = clients
+	}
+
+	clients[client] = true
+
+	return id
+}
+
+func (server *Server) unsubscribe(client *wsClient, topic string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	delete(client.subs, topic)
+
+	if clients, ok := server.subs[topic]; ok {
+		delete(clients, client)
+
+		if len(clients) == 0 {
+			delete(server.subs, topic)
+		}
+	}
+}
+
+func (server *Server) disconnect(client *wsClient) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for topic := range client.subs {
+		if clients, ok := server.subs[topic]; ok {
+			delete(clients, client)
+
+			if len(clients) == 0 {
+				delete(server.subs, topic)
+			}
+		}
+	}
+
+	// unacknowledged msg are redelivered to the next subscription
+	for _, msg := range client.pending {
+		delete(msg.delivered, client)
+	}
+}
+
+func (server *Server) publish(publisher *wsClient, params *PublishParams, ttl time.Duration) {
+	now := time.Now()
+
+	msg := &mailboxMessage{
+		data: &SubscriptionData{
+			Topic:       params.Topic,
+			Message:     params.Message,
+			PublishedAt: now.UnixNano() / int64(time.Millisecond),
+			Tag:         params.Tag,
+		},
+		publisher: publisher,
+		expired:   now.Add(ttl),
+		delivered: make(map[*wsClient]bool),
+	}
+
+	server.mutex.Lock()
+
+	mailbox := server.prune(params.Topic)
+
+	mailbox = append(mailbox, msg)
+
+	if len(mailbox) > server.options.mailboxLimit {
+		mailbox = mailbox[len(mailbox)-server.options.mailboxLimit:]
+	}
+
+	server.mailbox[params.Topic] = mailbox
+
+	var clients []*wsClient
+
+	for client := range server.subs[params.Topic] {
+		clients = append(clients, client)
+	}
+
+	server.mutex.Unlock()
+
+	for _, client := range clients {
+		server.deliver(client, msg)
+	}
+}
+
+// prune drop expired and acknowledged msg of topic, must be called with server lock held
+func (server *Server) prune(topic string) []*mailboxMessage {
+	now := time.Now()
+
+	var mailbox []*mailboxMessage
+
+	for _, msg := range server.mailbox[topic] {
+		if !msg.acked && now.Before(msg.expired) {
+			mailbox = append(mailbox, msg)
+		}
+	}
+
+	if len(mailbox) == 0 {
+		delete(server.mailbox, topic)
+	}
+
+	return mailbox
+}
+
+// flush deliver kept msg of topic to new subscription
+func (server *Server) flush(client *wsClient, topic string) {
+	server.mutex.Lock()
+	mailbox := server.prune(topic)
+	server.mailbox[topic] = mailbox
+	server.mutex.Unlock()
+
+	for _, msg := range mailbox {
+		server.deliver(client, msg)
+	}
+}
+
+func (server *Server) deliver(client *wsClient, msg *mailboxMessage) {
+	server.mutex.Lock()
+
+	id, ok := client.subs[msg.data.Topic]
+
+	if !ok || msg.publisher == client || msg.delivered[client] || msg.acked {
+		server.mutex.Unlock()
+		return
+	}
+
+	request, err := NewRequest(MethodSubscription, &SubscriptionParams{ID: id, Data: msg.data})
+
+	if err != nil {
+		server.mutex.Unlock()
+		server.logger.W("create subscription request error {@err}", err)
+		return
+	}
+
+	msg.delivered[client] = true
+	client.pending[request.ID] = msg
+
+	server.mutex.Unlock()
+
+	if err := client.write(request); err != nil {
+		server.logger.W("deliver msg to topic {@topic} error {@err}", msg.data.Topic, err)
+	}
+}
+
+func (server *Server) ack(client *wsClient, response *Message) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	msg, ok := client.pending[response.ID]
+
+	if !ok {
+		return
+	}
+
+	delete(client.pending, response.ID)
+
+	if response.Error != nil {
+		delete(msg.delivered, client)
+		return
+	}
+
+	msg.acked = true
+}
@@ -0,0 +1,209 @@
+package relay
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+const topic = "7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9"
+
+func startServer(t *testing.T, opts ...Option) *Server {
+	server := New(opts...)
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	return server
+}
+
+func dial(t *testing.T, server *Server) *websocket.Conn {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	require.NoError(t, err)
+
+	token, err := SignJWT(privateKey, server.URL(), time.Hour)
+
+	require.NoError(t, err)
+
+	conn, _, err := websocket.DefaultDialer.Dial(server.URL()+"?auth="+token, nil)
+
+	require.NoError(t, err)
+
+	return conn
+}
+
+func call(t *testing.T, conn *websocket.Conn, method string, params interface{}) {
+	msg, err := NewRequest(method, params)
+
+	require.NoError(t, err)
+
+	buff, err := json.Marshal(msg)
+
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, buff))
+}
+
+func recv(t *testing.T, conn *websocket.Conn) *Message {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, buff, err := conn.ReadMessage()
+
+	require.NoError(t, err)
+
+	var msg *Message
+
+	require.NoError(t, json.Unmarshal(buff, &msg))
+
+	return msg
+}
+
+func ack(t *testing.T, conn *websocket.Conn, msg *Message) {
+	rsp, err := NewResponse(msg.ID, true, nil)
+
+	require.NoError(t, err)
+
+	buff, err := json.Marshal(rsp)
+
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, buff))
+}
+
+func subscription(t *testing.T, msg *Message) *SubscriptionData {
+	require.Equal(t, MethodSubscription, msg.Method)
+
+	var params *SubscriptionParams
+
+	require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+	return params.Data
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	server := startServer(t)
+	defer server.Close()
+
+	sub := dial(t, server)
+	defer sub.Close()
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	call(t, sub, MethodSubscribe, &SubscribeParams{Topic: topic})
+
+	require.NotEmpty(t, recv(t, sub).Result)
+
+	call(t, pub, MethodPublish, &PublishParams{Topic: topic, Message: "hello", TTL: 300, Tag: 1108})
+
+	require.JSONEq(t, "true", string(recv(t, pub).Result))
+
+	msg := recv(t, sub)
+	data := subscription(t, msg)
+
+	require.Equal(t, "hello", data.Message)
+	require.Equal(t, 1108, data.Tag)
+
+	ack(t, sub, msg)
+
+	call(t, pub, MethodPublish, &PublishParams{Topic: topic, Message: "hello", TTL: 1})
+
+	require.Equal(t, CodeInvalidParams, recv(t, pub).Error.Code)
+}
+
+func TestMailbox(t *testing.T) {
+	server := startServer(t)
+	defer server.Close()
+
+	pub := dial(t, server)
+	defer pub.Close()
+
+	call(t, pub, MethodSubscribe, &SubscribeParams{Topic: topic})
+	recv(t, pub)
+
+	call(t, pub, MethodPublish, &PublishParams{Topic: topic, Message: "first", TTL: 300})
+	recv(t, pub)
+
+	call(t, pub, MethodPublish, &PublishParams{Topic: topic, Message: "expired", TTL: 300})
+	recv(t, pub)
+
+	server.mutex.Lock()
+	server.mailbox[topic][1].expired = time.Now()
+	server.mutex.Unlock()
+
+	// msg is not delivered back to publisher but kept for the late subscriber
+	sub := dial(t, server)
+
+	call(t, sub, MethodSubscribe, &SubscribeParams{Topic: topic})
+	recv(t, sub)
+
+	msg := recv(t, sub)
+
+	require.Equal(t, "first", subscription(t, msg).Message)
+
+	// unacknowledged msg is redelivered to next subscription
+	sub.Close()
+
+	sub = dial(t, server)
+	defer sub.Close()
+
+	call(t, sub, MethodSubscribe, &SubscribeParams{Topic: topic})
+	recv(t, sub)
+
+	msg = recv(t, sub)
+
+	require.Equal(t, "first", subscription(t, msg).Message)
+
+	ack(t, sub, msg)
+
+	require.Eventually(t, func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return len(server.prune(topic)) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAuth(t *testing.T) {
+	server := startServer(t, WithAuth(""))
+	defer server.Close()
+
+	_, rsp, err := websocket.DefaultDialer.Dial(server.URL(), nil)
+
+	require.Error(t, err)
+	require.Equal(t, 401, rsp.StatusCode)
+
+	conn := dial(t, server)
+	conn.Close()
+}
+
+func TestClose(t *testing.T) {
+	require.Equal(t, "", New().URL())
+
+	server := startServer(t)
+
+	conn := dial(t, server)
+	defer conn.Close()
+
+	call(t, conn, MethodSubscribe, &SubscribeParams{Topic: topic})
+
+	recv(t, conn)
+
+	require.NoError(t, server.Close())
+
+	// hijacked websocket connection is closed by server Close
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, _, err := conn.ReadMessage()
+
+	require.Error(t, err)
+
+	netErr, ok := err.(net.Error)
+
+	require.False(t, ok && netErr.Timeout(), "connection must be closed before read deadline")
+}
@@ -0,0 +1,172 @@
+package wc2
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/provider/wc2/relay"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	server := relay.New(relay.WithAuth(""))
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	defer server.Close()
+
+	dapp, err := New(&Options{
+		Role:               Dapp,
+		Metadata:           &Metadata{Name: "dapp"},
+		RequiredNamespaces: map[string]*ProposalNamespace{"eip155": {Chains: []string{"eip155:1"}}},
+	})
+
+	require.NoError(t, err)
+
+	wallet, err := New(&Options{
+		URL:        dapp.PairingURL().String(),
+		Metadata:   &Metadata{Name: "wallet"},
+		Namespaces: map[string]*Namespace{"eip155": {Accounts: []string{account}}},
+	})
+
+	require.NoError(t, err)
+
+	dappTransport, err := DialRelay(server.URL(), WithProjectID("test"))
+
+	require.NoError(t, err)
+
+	defer dappTransport.Close()
+
+	walletTransport, err := DialRelay(server.URL())
+
+	require.NoError(t, err)
+
+	defer walletTransport.Close()
+
+	walletErr := make(chan error, 1)
+
+	go func() {
+		walletErr <- wallet.Connect(walletTransport)
+	}()
+
+	require.NoError(t, dapp.Connect(dappTransport))
+	require.NoError(t, <-walletErr)
+
+	_, ok := dappTransport.Subscription(dapp.Session().Topic)
+
+	require.True(t, ok)
+
+	require.NoError(t, dapp.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`), dappTransport))
+
+	buff, err := wallet.Recv(walletTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "eth_accounts")
+}
+
+func TestDial(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	server := relay.New(relay.WithAuth(""))
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	defer server.Close()
+
+	SetRelay(server.URL())
+
+	defer SetRelay(DefaultRelayURL)
+
+	dapp, err := New(&Options{
+		Role:               Dapp,
+		Metadata:           &Metadata{Name: "dapp"},
+		RequiredNamespaces: map[string]*ProposalNamespace{"eip155": {Chains: []string{"eip155:1"}}},
+	})
+
+	require.NoError(t, err)
+
+	dappTransport, err := DialRelay(server.URL())
+
+	require.NoError(t, err)
+
+	defer dappTransport.Close()
+
+	dappErr := make(chan error, 1)
+
+	go func() {
+		dappErr <- dapp.Connect(dappTransport)
+	}()
+
+	// v2 pairing uri is routed to wc2 provider, which opens the relay transport
+	wallet, walletTransport, err := tun4go.Dial(dapp.PairingURL().String(), tun4go.WithParams(tun4go.Params{
+		"metadata":   marshal(&Metadata{Name: "wallet"}),
+		"namespaces": marshal(map[string]*Namespace{"eip155": {Accounts: []string{account}}}),
+	}))
+
+	require.NoError(t, err)
+
+	defer walletTransport.(io.Closer).Close()
+
+	require.NoError(t, <-dappErr)
+
+	require.Equal(t, Connected, wallet.(Tunnel).Status())
+	require.Equal(t, "dapp", wallet.(Tunnel).Session().Peer.Name)
+}
+
+func TestRelayContext(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	server := relay.New(relay.WithAuth(""))
+
+	require.NoError(t, server.Start("127.0.0.1:0"))
+
+	defer server.Close()
+
+	transport, err := DialRelay(server.URL())
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	var _ tun4go.ContextTransport = transport
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.ReadContext(ctx)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	SetRelay(server.URL())
+
+	defer SetRelay(DefaultRelayURL)
+
+	dapp, err := New(&Options{
+		Role:               Dapp,
+		Metadata:           &Metadata{Name: "dapp"},
+		RequiredNamespaces: map[string]*ProposalNamespace{"eip155": {Chains: []string{"eip155:1"}}},
+	})
+
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// handshake read blocked on the absent dapp is cancelled by ctx
+	_, _, err = tun4go.DialContext(ctx, dapp.PairingURL().String(), tun4go.WithParams(tun4go.Params{
+		"metadata":   marshal(&Metadata{Name: "wallet"}),
+		"namespaces": marshal(map[string]*Namespace{"eip155": {Accounts: []string{account}}}),
+	}))
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
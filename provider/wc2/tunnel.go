@@ -0,0 +1,999 @@
+package wc2
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+	"github.com/libs4go/tun4go/internal/jsonrpc"
+)
+
+// Status Tunnel status
+type Status = tun4go.Status
+
+// Status enum
+const (
+	Connecting    = tun4go.Connecting
+	Connected     = tun4go.Connected
+	Disconnecting = tun4go.Disconnecting
+	Disconnected  = tun4go.Disconnected
+)
+
+// Role tunnel side role
+type Role string
+
+// Role enum
+const (
+	Wallet Role = "wallet" // responder and session controller, pair with uri provide by dapp
+	Dapp   Role = "dapp"   // proposer, create pairing uri and wait wallet settle session
+)
+
+// Tunnel wc2 tunnel object with session accessors
+type Tunnel interface {
+	tun4go.StatusTunnel
+	tun4go.ContextTunnel
+
+	// PairingURL get the pairing uri peer should pair with
+	PairingURL() *URL
+
+	// Session get the settled session, returns nil before session settled
+	Session() *Session
+
+	// Ping send wc_sessionPing to peer, the peer acknowledgement is consumed by Recv
+	Ping(transport tun4go.Transport) error
+
+	// PingContext context aware Ping
+	PingContext(ctx context.Context, transport tun4go.Transport) error
+
+	// Extend extend session expiry, only wallet can extend session
+	Extend(transport tun4go.Transport) error
+
+	// ExtendContext context aware Extend
+	ExtendContext(ctx context.Context, transport tun4go.Transport) error
+}
+
+// Session settled session
+type Session struct {
+	Topic      string                // session topic
+	Namespaces map[string]*Namespace // approved namespaces
+	Expiry     int64                 // session expiry unix seconds
+	Peer       *Metadata             // peer metadata
+}
+
+type wc2Tunnel struct {
+	slf4go.Logger      `json:"-"`
+	Role               Role                          `json:"role"`
+	URL                *URL                          `json:"url"`
+	PairingKey         []byte                        `json:"pairing-key"`
+	PrivateKey         []byte                        `json:"private-key"`
+	PeerPublicKey      []byte                        `json:"peer-public-key"`
+	Topic              string                        `json:"topic"`
+	SessionKey         []byte                        `json:"session-key"`
+	Metadata           *Metadata                     `json:"metadata"`
+	PeerMetadata       *Metadata                     `json:"peer-metadata"`
+	RequiredNamespaces map[string]*ProposalNamespace `json:"required-namespaces,omitempty"`
+	Namespaces         map[string]*Namespace         `json:"namespaces"`
+	Expiry             int64                         `json:"expiry"`
+	State              Status                        `json:"status"`
+	pending            map[int64]string              // internal request id to method, acknowledged in Recv
+	mutex              sync.RWMutex                  // guard State, Namespaces, PeerMetadata, Expiry and pending
+}
+
+func newWC2Tunnel(params tun4go.Params) (*wc2Tunnel, error) {
+	options, err := optionsFromParams(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newTunnel(options)
+}
+
+func newTunnel(options *Options) (*wc2Tunnel, error) {
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	tunnel := &wc2Tunnel{
+		Logger:             slf4go.Get("wc2-tunnel"),
+		Role:               options.Role,
+		Metadata:           options.Metadata,
+		RequiredNamespaces: options.RequiredNamespaces,
+		Namespaces:         options.Namespaces,
+		State:              Disconnected,
+	}
+
+	if tunnel.Role == "" {
+		tunnel.Role = Wallet
+	}
+
+	if tunnel.Role == Dapp {
+		topic, err := randomBytes(32)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := randomBytes(32)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tunnel.PairingKey = key
+
+		tunnel.URL = &URL{
+			Topic:         hex.EncodeToString(topic),
+			Version:       "2",
+			RelayProtocol: RelayProtocol,
+			SymKey:        hex.EncodeToString(key),
+		}
+
+		return tunnel, nil
+	}
+
+	u, err := ParseURL(options.URL)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrParams, "Options.URL %s parse error", options.URL)
+	}
+
+	tunnel.URL = u
+	tunnel.PairingKey, _ = hex.DecodeString(u.SymKey)
+
+	return tunnel, nil
+}
+
+func fromContext(context []byte) (*wc2Tunnel, error) {
+	var tunnel *wc2Tunnel
+	err := json.Unmarshal(context, &tunnel)
+
+	if err != nil || tunnel == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal wc2Tunnel context error")
+	}
+
+	tunnel.Logger = slf4go.Get("wc2-tunnel")
+
+	if tunnel.Role == "" {
+		tunnel.Role = Wallet
+	}
+
+	return tunnel, nil
+}
+
+func (tunnel *wc2Tunnel) Status() tun4go.Status {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	return tunnel.State
+}
+
+func (tunnel *wc2Tunnel) setStatus(status Status) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	tunnel.State = status
+}
+
+func (tunnel *wc2Tunnel) PairingURL() *URL {
+	return tunnel.URL
+}
+
+func (tunnel *wc2Tunnel) Session() *Session {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	if tunnel.Topic == "" || tunnel.Expiry == 0 {
+		return nil
+	}
+
+	return &Session{
+		Topic:      tunnel.Topic,
+		Namespaces: tunnel.Namespaces,
+		Expiry:     tunnel.Expiry,
+		Peer:       tunnel.PeerMetadata,
+	}
+}
+
+func (tunnel *wc2Tunnel) Context() ([]byte, error) {
+	tunnel.mutex.RLock()
+	defer tunnel.mutex.RUnlock()
+
+	buff, err := json.Marshal(&tunnel)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal wc2Tunnel error")
+	}
+
+	return buff, nil
+}
+
+// keyOf get symmetric key of topic
+func (tunnel *wc2Tunnel) keyOf(topic string) ([]byte, error) {
+	switch topic {
+	case tunnel.Topic:
+		if tunnel.SessionKey != nil {
+			return tunnel.SessionKey, nil
+		}
+	case tunnel.URL.Topic:
+		return tunnel.PairingKey, nil
+	}
+
+	return nil, errors.Wrap(ErrMessage, "unknown topic %s", topic)
+}
+
+// peerKeyOf derive symmetric key of type-1 envelope sender
+func (tunnel *wc2Tunnel) peerKeyOf(senderPublicKey []byte) ([]byte, error) {
+	if tunnel.PrivateKey == nil {
+		return nil, errors.Wrap(ErrEnvelope, "type-1 envelope recv without key pair")
+	}
+
+	return deriveSymKey(tunnel.PrivateKey, senderPublicKey)
+}
+
+func (tunnel *wc2Tunnel) subscribe(ctx context.Context, topic string, transport tun4go.Transport) error {
+	buff, err := json.Marshal(&relayMessage{
+		Type:  "sub",
+		Topic: topic,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "marshal relayMessage error")
+	}
+
+	err = tun4go.WriteContext(ctx, transport, buff)
+
+	if err != nil {
+		return errors.Wrap(err, "write sub %s to transport error", topic)
+	}
+
+	return nil
+}
+
+func (tunnel *wc2Tunnel) publish(ctx context.Context, topic string, data []byte, tag int, ttl int64, transport tun4go.Transport) error {
+
+	tunnel.D("publish msg {@topic} {@msg}", topic, string(data))
+
+	key, err := tunnel.keyOf(topic)
+
+	if err != nil {
+		return err
+	}
+
+	message, err := seal(data, key, nil)
+
+	if err != nil {
+		return err
+	}
+
+	buff, err := json.Marshal(&relayMessage{
+		Type:    "pub",
+		Topic:   topic,
+		Message: message,
+		Tag:     tag,
+		TTL:     ttl,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "marshal relayMessage error")
+	}
+
+	err = tun4go.WriteContext(ctx, transport, buff)
+
+	if err != nil {
+		return errors.Wrap(err, "write to transport error")
+	}
+
+	return nil
+}
+
+// request publish sign api request with id
+func (tunnel *wc2Tunnel) request(ctx context.Context, topic string, id int64, method string, params interface{}, transport tun4go.Transport) error {
+	buff, err := json.Marshal(params)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal %s params error", method)
+	}
+
+	msg := &jsonRPCMessage{
+		ID:      id,
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  buff,
+	}
+
+	buff, err = json.Marshal(msg)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal %s error", method)
+	}
+
+	spec := methodSpecs[method]
+
+	return tunnel.publish(ctx, topic, buff, spec.Tag, spec.TTL, transport)
+}
+
+// respond publish sign api response of method, rpcErr is sent instead of result if not nil
+func (tunnel *wc2Tunnel) respond(ctx context.Context, topic string, method string, id int64, result interface{}, rpcErr *jsonRPCError, transport tun4go.Transport) error {
+	msg := &jsonRPCMessage{
+		ID:      id,
+		JSONRPC: "2.0",
+		Error:   rpcErr,
+	}
+
+	if rpcErr == nil {
+		buff, err := json.Marshal(result)
+
+		if err != nil {
+			return errors.Wrap(err, "marshal %s result error", method)
+		}
+
+		msg.Result = buff
+	}
+
+	buff, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal %s response error", method)
+	}
+
+	spec := methodSpecs[method]
+
+	return tunnel.publish(ctx, topic, buff, spec.ResponseTag, spec.TTL, transport)
+}
+
+// next read and decrypt next relay message
+func (tunnel *wc2Tunnel) next(ctx context.Context, transport tun4go.Transport) (string, *jsonRPCMessage, []byte, error) {
+	data, err := tun4go.ReadContext(ctx, transport)
+
+	if err != nil {
+		return "", nil, nil, errors.Wrap(err, "read from transport error")
+	}
+
+	var frame *relayMessage
+
+	if err := json.Unmarshal(data, &frame); err != nil || frame == nil || frame.Type != "pub" {
+		return "", nil, nil, errors.Wrap(ErrFormat, "unmarshal relayMessage error %s", string(data))
+	}
+
+	key, err := tunnel.keyOf(frame.Topic)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	buff, err := open(frame.Message, key, tunnel.peerKeyOf)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	tunnel.D("recv msg {@topic} {@msg}", frame.Topic, string(buff))
+
+	var msg *jsonRPCMessage
+
+	if err := json.Unmarshal(buff, &msg); err != nil || msg == nil {
+		return "", nil, nil, errors.Wrap(ErrFormat, "unmarshal json rpc message error %s", string(buff))
+	}
+
+	return frame.Topic, msg, buff, nil
+}
+
+// waitResponse read until the response of request id, other messages are skipped
+func (tunnel *wc2Tunnel) waitResponse(ctx context.Context, transport tun4go.Transport, id int64) (*jsonRPCMessage, error) {
+	for {
+		_, msg, buff, err := tunnel.next(ctx, transport)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if msg.Method != "" || msg.ID != id {
+			tunnel.W("skip unexpect msg {@msg}", string(buff))
+			continue
+		}
+
+		return msg, nil
+	}
+}
+
+func (tunnel *wc2Tunnel) Connect(transport tun4go.Transport) error {
+	return tunnel.ConnectContext(context.Background(), transport)
+}
+
+func (tunnel *wc2Tunnel) ConnectContext(ctx context.Context, transport tun4go.Transport) error {
+
+	tunnel.mutex.Lock()
+
+	if tunnel.State != Disconnected {
+		tunnel.mutex.Unlock()
+		return nil
+	}
+
+	tunnel.State = Connecting
+
+	tunnel.mutex.Unlock()
+
+	var err error
+
+	if tunnel.Role == Dapp {
+		err = tunnel.connectDapp(ctx, transport)
+	} else {
+		err = tunnel.connectWallet(ctx, transport)
+	}
+
+	if err != nil {
+		tunnel.setStatus(Disconnected)
+		return err
+	}
+
+	tunnel.setStatus(Connected)
+
+	return nil
+}
+
+func (tunnel *wc2Tunnel) connectDapp(ctx context.Context, transport tun4go.Transport) error {
+
+	if err := tunnel.subscribe(ctx, tunnel.URL.Topic, transport); err != nil {
+		return err
+	}
+
+	privateKey, publicKey, err := generateKeyPair()
+
+	if err != nil {
+		return err
+	}
+
+	tunnel.PrivateKey = privateKey
+
+	proposal := &sessionProposal{
+		Relays:             []*relayProtocol{{Protocol: tunnel.URL.RelayProtocol}},
+		RequiredNamespaces: tunnel.RequiredNamespaces,
+		Proposer: &participant{
+			PublicKey: hex.EncodeToString(publicKey),
+			Metadata:  tunnel.Metadata,
+		},
+	}
+
+	if proposal.RequiredNamespaces == nil {
+		proposal.RequiredNamespaces = make(map[string]*ProposalNamespace)
+	}
+
+	id := jsonrpc.NewID()
+
+	if err := tunnel.request(ctx, tunnel.URL.Topic, id, methodSessionPropose, proposal, transport); err != nil {
+		return err
+	}
+
+	response, err := tunnel.waitResponse(ctx, transport, id)
+
+	if err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return errors.Wrap(ErrRejected, "wallet reject session: (%d) %s", response.Error.Code, response.Error.Message)
+	}
+
+	var result *proposalResponse
+
+	if err := json.Unmarshal(response.Result, &result); err != nil || result == nil {
+		return errors.Wrap(ErrFormat, "unmarshal session propose response error: %s", string(response.Result))
+	}
+
+	peerPublicKey, err := hex.DecodeString(result.ResponderPublicKey)
+
+	if err != nil || len(peerPublicKey) != 32 {
+		return errors.Wrap(ErrFormat, "responderPublicKey %s must be 32 bytes hex", result.ResponderPublicKey)
+	}
+
+	if err := tunnel.agree(peerPublicKey); err != nil {
+		return err
+	}
+
+	if err := tunnel.subscribe(ctx, tunnel.Topic, transport); err != nil {
+		return err
+	}
+
+	for {
+		topic, msg, buff, err := tunnel.next(ctx, transport)
+
+		if err != nil {
+			return err
+		}
+
+		if topic != tunnel.Topic || msg.Method != methodSessionSettle {
+			tunnel.W("skip unexpect msg {@msg}", string(buff))
+			continue
+		}
+
+		return tunnel.handleSessionSettle(ctx, msg, transport)
+	}
+}
+
+// agree derive session key and topic with peer public key
+func (tunnel *wc2Tunnel) agree(peerPublicKey []byte) error {
+	key, err := deriveSymKey(tunnel.PrivateKey, peerPublicKey)
+
+	if err != nil {
+		return err
+	}
+
+	tunnel.PeerPublicKey = peerPublicKey
+	tunnel.SessionKey = key
+	tunnel.Topic = topicOf(key)
+
+	return nil
+}
+
+func (tunnel *wc2Tunnel) handleSessionSettle(ctx context.Context, msg *jsonRPCMessage, transport tun4go.Transport) error {
+	var settle *sessionSettle
+
+	if err := json.Unmarshal(msg.Params, &settle); err != nil || settle == nil || settle.Controller == nil {
+		return errors.Wrap(ErrFormat, "unmarshal session settle error: %s", string(msg.Params))
+	}
+
+	if settle.Controller.PublicKey != hex.EncodeToString(tunnel.PeerPublicKey) {
+		return errors.Wrap(ErrMessage, "session settle controller %s is not the responder", settle.Controller.PublicKey)
+	}
+
+	if err := validateNamespaces(settle.Namespaces); err != nil {
+		return err
+	}
+
+	if rpcErr := satisfy(tunnel.RequiredNamespaces, settle.Namespaces); rpcErr != nil {
+		if err := tunnel.respond(ctx, tunnel.Topic, methodSessionSettle, msg.ID, nil, rpcErr, transport); err != nil {
+			return err
+		}
+
+		return errors.Wrap(ErrNamespaces, "settled namespaces not satisfy required namespaces: %s", rpcErr.Message)
+	}
+
+	tunnel.mutex.Lock()
+	tunnel.Namespaces = settle.Namespaces
+	tunnel.PeerMetadata = settle.Controller.Metadata
+	tunnel.Expiry = settle.Expiry
+	tunnel.mutex.Unlock()
+
+	return tunnel.respond(ctx, tunnel.Topic, methodSessionSettle, msg.ID, true, nil, transport)
+}
+
+func (tunnel *wc2Tunnel) connectWallet(ctx context.Context, transport tun4go.Transport) error {
+
+	if expiry := tunnel.URL.ExpiryTimestamp; expiry != 0 && time.Now().Unix() > expiry {
+		return errors.Wrap(ErrExpired, "pairing %s expired at %d", tunnel.URL.Topic, expiry)
+	}
+
+	if err := tunnel.subscribe(ctx, tunnel.URL.Topic, transport); err != nil {
+		return err
+	}
+
+	var msg *jsonRPCMessage
+
+	for {
+		topic, request, buff, err := tunnel.next(ctx, transport)
+
+		if err != nil {
+			return err
+		}
+
+		if topic == tunnel.URL.Topic && request.Method == methodSessionPropose {
+			msg = request
+			break
+		}
+
+		tunnel.W("skip unexpect msg {@msg}", string(buff))
+	}
+
+	var proposal *sessionProposal
+
+	if err := json.Unmarshal(msg.Params, &proposal); err != nil || proposal == nil || proposal.Proposer == nil {
+		return errors.Wrap(ErrFormat, "unmarshal session proposal error: %s", string(msg.Params))
+	}
+
+	peerPublicKey, err := hex.DecodeString(proposal.Proposer.PublicKey)
+
+	if err != nil || len(peerPublicKey) != 32 {
+		return errors.Wrap(ErrFormat, "proposer publicKey %s must be 32 bytes hex", proposal.Proposer.PublicKey)
+	}
+
+	rpcErr := satisfy(proposal.RequiredNamespaces, tunnel.Namespaces)
+
+	if approver, ok := transport.(tun4go.Approver); ok && rpcErr == nil && !approver.Approve(msg.Params) {
+		rpcErr = &jsonRPCError{Code: codeUserRejected, Message: messageUserRejected}
+	}
+
+	if rpcErr != nil {
+		if err := tunnel.respond(ctx, tunnel.URL.Topic, methodSessionPropose, msg.ID, nil, rpcErr, transport); err != nil {
+			return err
+		}
+
+		return errors.Wrap(ErrRejected, "reject session proposal: (%d) %s", rpcErr.Code, rpcErr.Message)
+	}
+
+	privateKey, publicKey, err := generateKeyPair()
+
+	if err != nil {
+		return err
+	}
+
+	tunnel.PrivateKey = privateKey
+
+	if err := tunnel.agree(peerPublicKey); err != nil {
+		return err
+	}
+
+	tunnel.mutex.Lock()
+	tunnel.PeerMetadata = proposal.Proposer.Metadata
+	tunnel.mutex.Unlock()
+
+	tunnel.RequiredNamespaces = proposal.RequiredNamespaces
+
+	result := &proposalResponse{
+		Relay:              &relayProtocol{Protocol: tunnel.URL.RelayProtocol},
+		ResponderPublicKey: hex.EncodeToString(publicKey),
+	}
+
+	if err := tunnel.respond(ctx, tunnel.URL.Topic, methodSessionPropose, msg.ID, result, nil, transport); err != nil {
+		return err
+	}
+
+	if err := tunnel.subscribe(ctx, tunnel.Topic, transport); err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(sessionTTL).Unix()
+
+	settle := &sessionSettle{
+		Relay:      &relayProtocol{Protocol: tunnel.URL.RelayProtocol},
+		Namespaces: tunnel.Namespaces,
+		Controller: &participant{
+			PublicKey: hex.EncodeToString(publicKey),
+			Metadata:  tunnel.Metadata,
+		},
+		Expiry: expiry,
+	}
+
+	id := jsonrpc.NewID()
+
+	if err := tunnel.request(ctx, tunnel.Topic, id, methodSessionSettle, settle, transport); err != nil {
+		return err
+	}
+
+	response, err := tunnel.waitResponse(ctx, transport, id)
+
+	if err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return errors.Wrap(ErrRejected, "dapp reject session settle: (%d) %s", response.Error.Code, response.Error.Message)
+	}
+
+	tunnel.mutex.Lock()
+	tunnel.Expiry = expiry
+	tunnel.mutex.Unlock()
+
+	return nil
+}
+
+// checkSession check session is connected and not expired
+func (tunnel *wc2Tunnel) checkSession() error {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	if tunnel.State != Connected {
+		return errors.Wrap(ErrStatus, "session with invalid status %s", tunnel.State)
+	}
+
+	if time.Now().Unix() > tunnel.Expiry {
+		tunnel.State = Disconnected
+		return errors.Wrap(ErrExpired, "session %s expired at %d", tunnel.Topic, tunnel.Expiry)
+	}
+
+	return nil
+}
+
+func (tunnel *wc2Tunnel) Send(msg []byte, transport tun4go.Transport) error {
+	return tunnel.SendContext(context.Background(), msg, transport)
+}
+
+// SendContext send json rpc request wrapped as wc_sessionRequest, or json rpc response of session request
+func (tunnel *wc2Tunnel) SendContext(ctx context.Context, msg []byte, transport tun4go.Transport) error {
+
+	if err := tunnel.checkSession(); err != nil {
+		return err
+	}
+
+	var app *appMessage
+
+	if err := json.Unmarshal(msg, &app); err != nil || app == nil {
+		return errors.Wrap(ErrFormat, "unmarshal json rpc message error %s", string(msg))
+	}
+
+	if app.Method == "" {
+		spec := methodSpecs[methodSessionRequest]
+		return tunnel.publish(ctx, tunnel.Topic, msg, spec.ResponseTag, spec.TTL, transport)
+	}
+
+	chainID := app.ChainID
+
+	if chainID == "" {
+		tunnel.mutex.RLock()
+		chainID = defaultChain(tunnel.Namespaces)
+		tunnel.mutex.RUnlock()
+	}
+
+	if app.ID == 0 {
+		app.ID = jsonrpc.NewID()
+	}
+
+	params, err := json.Marshal(&sessionRequest{
+		Request: &sessionRequestBody{
+			Method: app.Method,
+			Params: app.Params,
+		},
+		ChainID: chainID,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "marshal session request error")
+	}
+
+	buff, err := json.Marshal(&jsonRPCMessage{
+		ID:      app.ID,
+		JSONRPC: "2.0",
+		Method:  methodSessionRequest,
+		Params:  params,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "marshal session request error")
+	}
+
+	spec := methodSpecs[methodSessionRequest]
+
+	return tunnel.publish(ctx, tunnel.Topic, buff, spec.Tag, spec.TTL, transport)
+}
+
+func (tunnel *wc2Tunnel) Recv(transport tun4go.Transport) ([]byte, error) {
+	return tunnel.RecvContext(context.Background(), transport)
+}
+
+// RecvContext recv unwrapped wc_sessionRequest or json rpc response,
+// wc_sessionPing, wc_sessionExtend and wc_sessionUpdate are handled in place
+func (tunnel *wc2Tunnel) RecvContext(ctx context.Context, transport tun4go.Transport) ([]byte, error) {
+	for {
+		if err := tunnel.checkSession(); err != nil {
+			return nil, err
+		}
+
+		topic, msg, buff, err := tunnel.next(ctx, transport)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if topic != tunnel.Topic {
+			tunnel.W("skip msg {@msg} of topic {@topic}", string(buff), topic)
+			continue
+		}
+
+		if msg.Method == "" {
+			tunnel.mutex.Lock()
+			method, ok := tunnel.pending[msg.ID]
+			delete(tunnel.pending, msg.ID)
+			tunnel.mutex.Unlock()
+
+			if ok {
+				if msg.Error != nil {
+					tunnel.W("{@method} rejected by peer: ({@code}) {@message}", method, msg.Error.Code, msg.Error.Message)
+				}
+
+				continue
+			}
+
+			return buff, nil
+		}
+
+		switch msg.Method {
+		case methodSessionPing:
+			err = tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, true, nil, transport)
+		case methodSessionExtend:
+			err = tunnel.handleSessionExtend(ctx, msg, transport)
+		case methodSessionUpdate:
+			err = tunnel.handleSessionUpdate(ctx, msg, transport)
+		case methodSessionDelete:
+			return nil, tunnel.handleSessionDelete(ctx, msg, transport)
+		case methodSessionRequest:
+			return tunnel.unwrapSessionRequest(msg)
+		default:
+			return buff, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (tunnel *wc2Tunnel) unwrapSessionRequest(msg *jsonRPCMessage) ([]byte, error) {
+	var request *sessionRequest
+
+	if err := json.Unmarshal(msg.Params, &request); err != nil || request == nil || request.Request == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal session request error: %s", string(msg.Params))
+	}
+
+	buff, err := json.Marshal(&appMessage{
+		ID:      msg.ID,
+		JSONRPC: "2.0",
+		Method:  request.Request.Method,
+		Params:  request.Request.Params,
+		ChainID: request.ChainID,
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal session request error")
+	}
+
+	return buff, nil
+}
+
+func (tunnel *wc2Tunnel) handleSessionExtend(ctx context.Context, msg *jsonRPCMessage, transport tun4go.Transport) error {
+	var extend *sessionExtend
+
+	if err := json.Unmarshal(msg.Params, &extend); err != nil || extend == nil {
+		return errors.Wrap(ErrFormat, "unmarshal session extend error: %s", string(msg.Params))
+	}
+
+	// only the controller wallet can extend, and not beyond the session ttl
+	max := time.Now().Add(sessionTTL).Unix()
+
+	tunnel.mutex.Lock()
+
+	if tunnel.Role != Dapp || extend.Expiry <= tunnel.Expiry || extend.Expiry > max {
+		tunnel.mutex.Unlock()
+		rpcErr := &jsonRPCError{Code: codeInvalidExtendRequest, Message: "Invalid session extend request."}
+		return tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, nil, rpcErr, transport)
+	}
+
+	tunnel.Expiry = extend.Expiry
+
+	tunnel.mutex.Unlock()
+
+	return tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, true, nil, transport)
+}
+
+func (tunnel *wc2Tunnel) handleSessionUpdate(ctx context.Context, msg *jsonRPCMessage, transport tun4go.Transport) error {
+	var update *sessionUpdate
+
+	if err := json.Unmarshal(msg.Params, &update); err != nil || update == nil {
+		return errors.Wrap(ErrFormat, "unmarshal session update error: %s", string(msg.Params))
+	}
+
+	if tunnel.Role != Dapp || validateNamespaces(update.Namespaces) != nil {
+		rpcErr := &jsonRPCError{Code: codeInvalidSessionRequest, Message: "Invalid session update request."}
+		return tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, nil, rpcErr, transport)
+	}
+
+	if rpcErr := satisfy(tunnel.RequiredNamespaces, update.Namespaces); rpcErr != nil {
+		return tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, nil, rpcErr, transport)
+	}
+
+	tunnel.mutex.Lock()
+	tunnel.Namespaces = update.Namespaces
+	tunnel.mutex.Unlock()
+
+	return tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, true, nil, transport)
+}
+
+func (tunnel *wc2Tunnel) handleSessionDelete(ctx context.Context, msg *jsonRPCMessage, transport tun4go.Transport) error {
+	var reason *sessionDelete
+
+	if err := json.Unmarshal(msg.Params, &reason); err != nil || reason == nil {
+		reason = &sessionDelete{}
+	}
+
+	tunnel.setStatus(Disconnected)
+
+	if err := tunnel.respond(ctx, tunnel.Topic, msg.Method, msg.ID, true, nil, transport); err != nil {
+		tunnel.W("ack session delete error {@err}", err)
+	}
+
+	return errors.Wrap(ErrDisconnected, "peer delete session %s: (%d) %s", tunnel.Topic, reason.Code, reason.Message)
+}
+
+// internal send sign api request of session and remember it for Recv to consume the acknowledgement
+func (tunnel *wc2Tunnel) internal(ctx context.Context, method string, params interface{}, transport tun4go.Transport) error {
+	id := jsonrpc.NewID()
+
+	// remember it before publishing, the acknowledgement may be read by concurrent Recv at once
+	tunnel.mutex.Lock()
+
+	if tunnel.pending == nil {
+		tunnel.pending = make(map[int64]string)
+	}
+
+	tunnel.pending[id] = method
+
+	tunnel.mutex.Unlock()
+
+	if err := tunnel.request(ctx, tunnel.Topic, id, method, params, transport); err != nil {
+		tunnel.mutex.Lock()
+		delete(tunnel.pending, id)
+		tunnel.mutex.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func (tunnel *wc2Tunnel) Ping(transport tun4go.Transport) error {
+	return tunnel.PingContext(context.Background(), transport)
+}
+
+func (tunnel *wc2Tunnel) PingContext(ctx context.Context, transport tun4go.Transport) error {
+	if err := tunnel.checkSession(); err != nil {
+		return err
+	}
+
+	return tunnel.internal(ctx, methodSessionPing, struct{}{}, transport)
+}
+
+func (tunnel *wc2Tunnel) Extend(transport tun4go.Transport) error {
+	return tunnel.ExtendContext(context.Background(), transport)
+}
+
+func (tunnel *wc2Tunnel) ExtendContext(ctx context.Context, transport tun4go.Transport) error {
+	if tunnel.Role != Wallet {
+		return errors.Wrap(ErrParams, "only wallet can extend session")
+	}
+
+	if err := tunnel.checkSession(); err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(sessionTTL).Unix()
+
+	if err := tunnel.internal(ctx, methodSessionExtend, &sessionExtend{Expiry: expiry}, transport); err != nil {
+		return err
+	}
+
+	tunnel.mutex.Lock()
+
+	if expiry > tunnel.Expiry {
+		tunnel.Expiry = expiry
+	}
+
+	tunnel.mutex.Unlock()
+
+	return nil
+}
+
+// Disconnect send wc_sessionDelete to peer
+func (tunnel *wc2Tunnel) Disconnect(transport tun4go.Transport) error {
+	return tunnel.DisconnectContext(context.Background(), transport)
+}
+
+// DisconnectContext send wc_sessionDelete to peer
+func (tunnel *wc2Tunnel) DisconnectContext(ctx context.Context, transport tun4go.Transport) error {
+
+	if tunnel.Status() == Connected {
+		reason := &sessionDelete{Code: codeUserDisconnected, Message: messageUserDisconnected}
+
+		if err := tunnel.internal(ctx, methodSessionDelete, reason, transport); err != nil {
+			return err
+		}
+	}
+
+	tunnel.setStatus(Disconnected)
+
+	return nil
+}
@@ -0,0 +1,497 @@
+package wc2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/scf4go"
+	_ "github.com/libs4go/scf4go/codec" //
+	"github.com/libs4go/scf4go/reader/file"
+	"github.com/libs4go/slf4go"
+	_ "github.com/libs4go/slf4go/backend/console"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+const account = "eip155:1:0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549"
+
+func init() {
+
+	config := scf4go.New()
+
+	err := config.Load(file.New(file.Yaml("./slf4go.yaml")))
+
+	if err != nil {
+		panic(err)
+	}
+
+	err = slf4go.Config(config)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// hub in memory relay routing pub frames to topic subscribers except the publisher,
+// frames reach no subscriber are queued until the topic is subscribed
+type hub struct {
+	sync.Mutex
+	subs  map[string][]*hubTransport
+	queue map[string][][]byte
+}
+
+func newHub() *hub {
+	return &hub{
+		subs:  make(map[string][]*hubTransport),
+		queue: make(map[string][][]byte),
+	}
+}
+
+type hubTransport struct {
+	hub   *hub
+	inbox chan []byte
+	once  sync.Once
+}
+
+func (h *hub) transport() *hubTransport {
+	return &hubTransport{hub: h, inbox: make(chan []byte, 100)}
+}
+
+func (transport *hubTransport) Read() ([]byte, error) {
+	buff, ok := <-transport.inbox
+
+	if !ok {
+		return nil, io.EOF
+	}
+
+	return buff, nil
+}
+
+func (transport *hubTransport) Write(buff []byte) error {
+	var msg *relayMessage
+
+	if err := json.Unmarshal(buff, &msg); err != nil {
+		return err
+	}
+
+	h := transport.hub
+
+	h.Lock()
+	defer h.Unlock()
+
+	if msg.Type == "sub" {
+		h.subs[msg.Topic] = append(h.subs[msg.Topic], transport)
+
+		for _, queued := range h.queue[msg.Topic] {
+			transport.inbox <- queued
+		}
+
+		delete(h.queue, msg.Topic)
+
+		return nil
+	}
+
+	delivered := false
+
+	for _, sub := range h.subs[msg.Topic] {
+		if sub != transport {
+			sub.inbox <- buff
+			delivered = true
+		}
+	}
+
+	if !delivered {
+		h.queue[msg.Topic] = append(h.queue[msg.Topic], buff)
+	}
+
+	return nil
+}
+
+func (transport *hubTransport) Close() error {
+	transport.once.Do(func() { close(transport.inbox) })
+	return nil
+}
+
+type rejectTransport struct {
+	*hubTransport
+}
+
+func (transport *rejectTransport) Approve(context []byte) bool {
+	return false
+}
+
+type pairing struct {
+	dapp            Tunnel
+	dappTransport   *hubTransport
+	wallet          Tunnel
+	walletTransport *hubTransport
+}
+
+func (p *pairing) Close() {
+	p.dappTransport.Close()
+	p.walletTransport.Close()
+}
+
+func newPairing(t *testing.T) (*pairing, error) {
+	h := newHub()
+
+	dapp, err := tun4go.New("wc2", tun4go.Params{
+		"role":               string(Dapp),
+		"metadata":           marshal(&Metadata{Name: "dapp"}),
+		"requiredNamespaces": `{"eip155":{"chains":["eip155:1"],"methods":["eth_sendTransaction"],"events":["accountsChanged"]}}`,
+	})
+
+	require.NoError(t, err)
+
+	wallet, err := tun4go.New("wc2", tun4go.Params{
+		"url":      dapp.(Tunnel).PairingURL().String(),
+		"metadata": marshal(&Metadata{Name: "wallet"}),
+		"namespaces": marshal(map[string]*Namespace{
+			"eip155": {
+				Accounts: []string{account},
+				Methods:  []string{"eth_sendTransaction", "personal_sign"},
+				Events:   []string{"accountsChanged", "chainChanged"},
+			},
+		}),
+	})
+
+	require.NoError(t, err)
+
+	p := &pairing{
+		dapp:            dapp.(Tunnel),
+		dappTransport:   h.transport(),
+		wallet:          wallet.(Tunnel),
+		walletTransport: h.transport(),
+	}
+
+	walletErr := make(chan error, 1)
+
+	go func() {
+		walletErr <- p.wallet.Connect(p.walletTransport)
+	}()
+
+	dappErr := p.dapp.Connect(p.dappTransport)
+
+	if err := <-walletErr; err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	if dappErr != nil {
+		p.Close()
+		return nil, dappErr
+	}
+
+	return p, nil
+}
+
+func pair(t *testing.T) *pairing {
+	p, err := newPairing(t)
+
+	require.NoError(t, err)
+
+	return p
+}
+
+func marshal(v interface{}) string {
+	buff, _ := json.Marshal(v)
+
+	return string(buff)
+}
+
+func TestTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	require.Equal(t, Connected, p.dapp.Status())
+	require.Equal(t, "wallet", p.dapp.Session().Peer.Name)
+	require.Equal(t, "dapp", p.wallet.Session().Peer.Name)
+	require.Equal(t, p.wallet.Session().Topic, p.dapp.Session().Topic)
+	require.Equal(t, []string{account}, p.dapp.Session().Namespaces["eip155"].Accounts)
+
+	err := p.dapp.Send([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_sendTransaction","params":[]}`), p.dappTransport)
+
+	require.NoError(t, err)
+
+	buff, err := p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{"id":1,"jsonrpc":"2.0","method":"eth_sendTransaction","params":[],"chainId":"eip155:1"}`, string(buff))
+
+	err = p.wallet.Send([]byte(`{"id":1,"jsonrpc":"2.0","result":"0x01"}`), p.walletTransport)
+
+	require.NoError(t, err)
+
+	buff, err = p.dapp.Recv(p.dappTransport)
+
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{"id":1,"jsonrpc":"2.0","result":"0x01"}`, string(buff))
+}
+
+func TestPingExtend(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	require.Error(t, p.dapp.Extend(p.dappTransport))
+
+	expiry := p.dapp.Session().Expiry
+
+	require.NoError(t, p.wallet.Ping(p.walletTransport))
+	require.NoError(t, p.wallet.Extend(p.walletTransport))
+	require.NoError(t, p.wallet.Send([]byte(`{"id":2,"jsonrpc":"2.0","method":"eth_chainId"}`), p.walletTransport))
+
+	// ping and extend are handled in place by Recv
+	buff, err := p.dapp.Recv(p.dappTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "eth_chainId")
+	require.True(t, p.dapp.Session().Expiry >= expiry)
+
+	require.NoError(t, p.dapp.Send([]byte(`{"id":2,"jsonrpc":"2.0","result":"0x1"}`), p.dappTransport))
+
+	// ping and extend acknowledgements are consumed
+	buff, err = p.wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":2,"jsonrpc":"2.0","result":"0x1"}`, string(buff))
+}
+
+func TestDisconnect(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	require.NoError(t, p.wallet.Disconnect(p.walletTransport))
+	require.Equal(t, Disconnected, p.wallet.Status())
+
+	_, err := p.dapp.Recv(p.dappTransport)
+
+	require.True(t, errors.Is(err, ErrDisconnected))
+	require.Equal(t, Disconnected, p.dapp.Status())
+}
+
+func TestReject(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	h := newHub()
+
+	dapp, err := New(&Options{
+		Role:     Dapp,
+		Metadata: &Metadata{Name: "dapp"},
+		RequiredNamespaces: map[string]*ProposalNamespace{
+			"eip155": {Chains: []string{"eip155:137"}},
+		},
+	})
+
+	require.NoError(t, err)
+
+	wallet, err := New(&Options{
+		URL:        dapp.PairingURL().String(),
+		Metadata:   &Metadata{Name: "wallet"},
+		Namespaces: map[string]*Namespace{"eip155": {Accounts: []string{account}}},
+	})
+
+	require.NoError(t, err)
+
+	dappTransport, walletTransport := h.transport(), h.transport()
+	defer dappTransport.Close()
+	defer walletTransport.Close()
+
+	walletErr := make(chan error, 1)
+
+	go func() {
+		walletErr <- wallet.Connect(walletTransport)
+	}()
+
+	require.True(t, errors.Is(dapp.Connect(dappTransport), ErrRejected))
+	require.True(t, errors.Is(<-walletErr, ErrRejected))
+	require.Equal(t, Disconnected, wallet.Status())
+
+	// approver reject
+	dapp, err = New(&Options{Role: Dapp, Metadata: &Metadata{Name: "dapp"}})
+
+	require.NoError(t, err)
+
+	wallet, err = New(&Options{
+		URL:        dapp.PairingURL().String(),
+		Metadata:   &Metadata{Name: "wallet"},
+		Namespaces: map[string]*Namespace{"eip155": {Accounts: []string{account}}},
+	})
+
+	require.NoError(t, err)
+
+	go func() {
+		walletErr <- wallet.Connect(&rejectTransport{walletTransport})
+	}()
+
+	require.True(t, errors.Is(dapp.Connect(dappTransport), ErrRejected))
+	require.True(t, errors.Is(<-walletErr, ErrRejected))
+}
+
+func TestContext(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	buff, err := p.wallet.Context()
+
+	require.NoError(t, err)
+
+	wallet, err := tun4go.FromContext("wc2", buff)
+
+	require.NoError(t, err)
+
+	require.NoError(t, p.dapp.Send([]byte(`{"id":3,"jsonrpc":"2.0","method":"personal_sign","params":["0x00"]}`), p.dappTransport))
+
+	buff, err = wallet.Recv(p.walletTransport)
+
+	require.NoError(t, err)
+	require.Contains(t, string(buff), "personal_sign")
+}
+
+func TestOptions(t *testing.T) {
+	_, err := New(&Options{Metadata: &Metadata{}, URL: "wc:x@2"})
+
+	require.True(t, errors.Is(err, ErrParams))
+
+	_, err = New(&Options{
+		Role:               Dapp,
+		Metadata:           &Metadata{},
+		RequiredNamespaces: map[string]*ProposalNamespace{"eip155": {Chains: []string{"cosmos:hub"}}},
+	})
+
+	require.True(t, errors.Is(err, ErrParams))
+
+	_, err = New(&Options{
+		URL:        "wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2?relay-protocol=irn&symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303",
+		Metadata:   &Metadata{},
+		Namespaces: map[string]*Namespace{"eip155": {Accounts: []string{"0x120f18F5B8EdCaA3c083F9464c57C11D81a9E549"}}},
+	})
+
+	require.True(t, errors.Is(err, ErrParams))
+}
+
+func TestConcurrentTunnel(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	p := pair(t)
+	defer p.Close()
+
+	const n = 20
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 4*n)
+
+	wg.Add(3)
+
+	// wallet pings, extends and sends requests while both sides receive
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			errs <- p.wallet.Ping(p.walletTransport)
+			errs <- p.wallet.Extend(p.walletTransport)
+			errs <- p.wallet.Send([]byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","method":"eth_chainId"}`, i+1)), p.walletTransport)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			buff, err := p.dapp.Recv(p.dappTransport)
+
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			var msg struct {
+				ID int64 `json:"id"`
+			}
+
+			if err := json.Unmarshal(buff, &msg); err != nil {
+				errs <- err
+				return
+			}
+
+			errs <- p.dapp.Send([]byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":"0x1"}`, msg.ID)), p.dappTransport)
+
+			p.dapp.Session()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		// ping and extend acknowledgements are consumed meanwhile
+		for i := 0; i < n; i++ {
+			if _, err := p.wallet.Recv(p.walletTransport); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, Connected, p.wallet.Status())
+	require.Equal(t, Connected, p.dapp.Status())
+}
+
+func TestPairingExpired(t *testing.T) {
+
+	defer slf4go.Sync()
+
+	dapp, err := New(&Options{
+		Role:               Dapp,
+		Metadata:           &Metadata{Name: "dapp"},
+		RequiredNamespaces: map[string]*ProposalNamespace{"eip155": {Chains: []string{"eip155:1"}}},
+	})
+
+	require.NoError(t, err)
+
+	u := *dapp.PairingURL()
+
+	u.ExpiryTimestamp = time.Now().Add(-time.Minute).Unix()
+
+	wallet, err := New(&Options{
+		URL:        u.String(),
+		Metadata:   &Metadata{Name: "wallet"},
+		Namespaces: map[string]*Namespace{"eip155": {Accounts: []string{account}}},
+	})
+
+	require.NoError(t, err)
+
+	transport := newHub().transport()
+	defer transport.Close()
+
+	require.True(t, errors.Is(wallet.Connect(transport), ErrExpired))
+	require.Equal(t, Disconnected, wallet.Status())
+}
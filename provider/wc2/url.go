@@ -0,0 +1,90 @@
+package wc2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	neturl "net/url"
+
+	"github.com/libs4go/errors"
+)
+
+// RelayProtocol default relay protocol
+const RelayProtocol = "irn"
+
+// URL wallet connect v2 pairing uri
+type URL struct {
+	Topic           string `json:"topic"`                      // pairing topic
+	Version         string `json:"version"`                    // protocol version, always 2
+	RelayProtocol   string `json:"relay-protocol"`             // relay protocol, default is irn
+	SymKey          string `json:"sym-key"`                    // pairing symmetric key hex string
+	ExpiryTimestamp int64  `json:"expiry-timestamp,omitempty"` // optional pairing expiry unix seconds
+}
+
+// ParseURL parse pairing uri string as URL object
+func ParseURL(url string) (*URL, error) {
+
+	if !strings.HasPrefix(url, "wc:") {
+		return nil, errors.Wrap(ErrURL, "parse %s error, expect wc: scheme", url)
+	}
+
+	u, err := neturl.Parse(strings.Replace(url, "wc:", "wc://", 1))
+
+	if err != nil {
+		return nil, errors.Wrap(ErrURL, "parse %s error", url)
+	}
+
+	if u.Host != "2" {
+		return nil, errors.Wrap(ErrURL, "parse %s error, unsupported version %s", url, u.Host)
+	}
+
+	topic := u.User.Username()
+
+	if len(topic) != 64 {
+		return nil, errors.Wrap(ErrURL, "parse %s error, topic must be 32 bytes hex", url)
+	}
+
+	query := u.Query()
+
+	relay := query.Get("relay-protocol")
+
+	if relay == "" {
+		return nil, errors.Wrap(ErrURL, "parse %s error, relay-protocol not found", url)
+	}
+
+	symKey := query.Get("symKey")
+
+	if key, err := hex.DecodeString(symKey); err != nil || len(key) != 32 {
+		return nil, errors.Wrap(ErrURL, "parse %s error, symKey must be 32 bytes hex", url)
+	}
+
+	pairing := &URL{
+		Topic:         topic,
+		Version:       u.Host,
+		RelayProtocol: relay,
+		SymKey:        symKey,
+	}
+
+	if expiry := query.Get("expiryTimestamp"); expiry != "" {
+		pairing.ExpiryTimestamp, err = strconv.ParseInt(expiry, 10, 64)
+
+		if err != nil {
+			return nil, errors.Wrap(ErrURL, "parse %s error, invalid expiryTimestamp", url)
+		}
+	}
+
+	return pairing, nil
+}
+
+// String implement Stringer
+func (url *URL) String() string {
+	str := fmt.Sprintf("wc:%s@%s?relay-protocol=%s&symKey=%s", url.Topic, url.Version, neturl.QueryEscape(url.RelayProtocol), url.SymKey)
+
+	if url.ExpiryTimestamp != 0 {
+		str = fmt.Sprintf("%s&expiryTimestamp=%d", str, url.ExpiryTimestamp)
+	}
+
+	return str
+}
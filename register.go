@@ -2,8 +2,11 @@ package tun4go
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/libs4go/errors"
 	"github.com/libs4go/sdi4go"
 )
 
@@ -18,15 +21,78 @@ func getInjector() sdi4go.Injector {
 	return injector
 }
 
-// RegisterProvider .
-func RegisterProvider(provider Provider) {
+// RegisterProvider register provider and the uri schemes it handles,
+// scheme@version, e.g. wc@2, binds provider to uris of the protocol version only
+func RegisterProvider(provider Provider, schemes ...string) {
 	getInjector().Bind(fmt.Sprintf("provider_%s", provider.Name()), sdi4go.Singleton(provider))
+
+	for _, scheme := range schemes {
+		getInjector().Bind(fmt.Sprintf("scheme_%s", strings.ToLower(scheme)), sdi4go.Singleton(&schemeProvider{provider}))
+	}
+}
+
+// schemeProvider wrap provider bound to scheme, so Providers() does not list it twice
+type schemeProvider struct {
+	provider Provider
+}
+
+// LookupScheme get provider registered for uri scheme, ok is false if not found
+func LookupScheme(scheme string) (provider Provider, ok bool) {
+	var sp *schemeProvider
+
+	if err := getInjector().Create(fmt.Sprintf("scheme_%s", strings.ToLower(scheme)), &sp); err != nil {
+		return nil, false
+	}
+
+	return sp.provider, true
 }
 
 func getProvider(name string, objectPtr interface{}) error {
 	return getInjector().Create(fmt.Sprintf("provider_%s", name), objectPtr)
 }
 
+// LookupProvider get registered provider by name, ok is false if not found
+func LookupProvider(name string) (provider Provider, ok bool) {
+	if err := getProvider(name, &provider); err != nil {
+		return nil, false
+	}
+
+	return provider, true
+}
+
+// Providers list registered provider names in order
+func Providers() []string {
+	var providers []Provider
+
+	getInjector().CreateAll(&providers)
+
+	var names []string
+
+	for _, provider := range providers {
+		names = append(names, provider.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// RegisterEncoding .
+func RegisterEncoding(encoding Encoding) {
+	getInjector().Bind(fmt.Sprintf("encoding_%s", encoding.Name()), sdi4go.Singleton(encoding))
+}
+
 func getEncoding(name string, objectPtr interface{}) error {
 	return getInjector().Create(fmt.Sprintf("encoding_%s", name), objectPtr)
 }
+
+// GetEncoding get registered encoding by name
+func GetEncoding(name string) (Encoding, error) {
+	var encoding Encoding
+
+	if err := getEncoding(name, &encoding); err != nil {
+		return nil, errors.Wrap(ErrEncodingNotFound, "encoding with name %s not found, call RegisterEncoding first", name)
+	}
+
+	return encoding, nil
+}
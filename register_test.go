@@ -0,0 +1,130 @@
+package tun4go
+
+import (
+	"testing"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockProvider struct {
+}
+
+func (provider *mockProvider) Name() string {
+	return "mock"
+}
+
+func (provider *mockProvider) FromContext(context []byte) (Tunnel, error) {
+	return &mockTunnel{}, nil
+}
+
+func (provider *mockProvider) New(params Params) (Tunnel, error) {
+	return &mockTunnel{}, nil
+}
+
+// mock2Provider handles version 2 mock uris and opens its own transport
+type mock2Provider struct {
+	mockProvider
+}
+
+func (provider *mock2Provider) Name() string {
+	return "mock2"
+}
+
+func (provider *mock2Provider) NewTransport(uri string) (Transport, error) {
+	return newChanTransport(), nil
+}
+
+func init() {
+	RegisterProvider(&mockProvider{}, "mock")
+	RegisterProvider(&mock2Provider{}, "mock@2")
+}
+
+func TestProviderNotFound(t *testing.T) {
+	_, err := New("unknown", Params{})
+
+	require.True(t, errors.Is(err, ErrProviderNotFound))
+
+	_, err = FromContext("unknown", nil)
+
+	require.True(t, errors.Is(err, ErrProviderNotFound))
+
+	_, ok := LookupProvider("unknown")
+
+	require.False(t, ok)
+}
+
+func TestProviders(t *testing.T) {
+	provider, ok := LookupProvider("mock")
+
+	require.True(t, ok)
+	require.Equal(t, "mock", provider.Name())
+
+	require.Contains(t, Providers(), "mock")
+
+	_, err := New("mock", Params{})
+
+	require.NoError(t, err)
+}
+
+func TestLookupScheme(t *testing.T) {
+	_, ok := LookupScheme("unknown")
+
+	require.False(t, ok)
+
+	_, _, err := Dial("unknown:1234")
+
+	require.True(t, errors.Is(err, ErrProviderNotFound))
+
+	_, _, err = Dial("1234")
+
+	require.True(t, errors.Is(err, ErrScheme))
+
+	provider, ok := LookupScheme("MOCK")
+
+	require.True(t, ok)
+	require.Equal(t, "mock", provider.Name())
+
+	require.Equal(t, 1, countOf(Providers(), "mock"))
+
+	transport := newChanTransport()
+
+	tunnel, _, err := Dial("mock:1234", WithTransport(transport))
+
+	require.NoError(t, err)
+	require.Equal(t, Connected, tunnel.(StatusTunnel).Status())
+
+	_, _, err = Dial("mock:1234")
+
+	require.True(t, errors.Is(err, ErrScheme))
+}
+
+func TestLookupSchemeVersion(t *testing.T) {
+	provider, ok := LookupScheme("mock@2")
+
+	require.True(t, ok)
+	require.Equal(t, "mock2", provider.Name())
+
+	// mock2 opens the transport of version 2 uri
+	tunnel, _, err := Dial("mock:1234@2?key=value")
+
+	require.NoError(t, err)
+	require.Equal(t, Connected, tunnel.(StatusTunnel).Status())
+
+	// other versions fall back to the scheme provider
+	_, _, err = Dial("mock:1234@1?key=value")
+
+	require.True(t, errors.Is(err, ErrScheme))
+}
+
+func countOf(names []string, name string) int {
+	n := 0
+
+	for _, v := range names {
+		if v == name {
+			n++
+		}
+	}
+
+	return n
+}
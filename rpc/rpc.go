@@ -0,0 +1,725 @@
+// Package rpc implement json rpc 2.0 session over tun4go.Tunnel
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+)
+
+const errVendor = "rpc"
+
+// errors
+var (
+	ErrClosed  = errors.New("rpc session closed", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrFormat  = errors.New("json rpc message format error", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrTimeout = errors.New("json rpc batch reply timeout", errors.WithCode(-3), errors.WithVendor(errVendor))
+)
+
+// JSON RPC 2.0 predefined error code
+const (
+	CodeParseError     int64 = -32700
+	CodeInvalidRequest int64 = -32600
+	CodeMethodNotFound int64 = -32601
+	CodeInvalidParams  int64 = -32602
+	CodeInternalError  int64 = -32603
+)
+
+// Error json rpc error object
+type Error struct {
+	Code    int64       `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("json rpc error (%d) %s", err.Code, err.Message)
+}
+
+// Request json rpc request or notification, ID is the raw json number or string id, notification's ID is nil
+type Request struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	batch   *batch          // batch the request belongs to, nil for single request
+	slot    int             // reply index in batch
+}
+
+// Response json rpc response, ID is the raw id of request, null for error response of request whose id is unknown
+type Response struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Kind json rpc message kind
+type Kind string
+
+// Kind enum
+const (
+	KindRequest      Kind = "request"
+	KindNotification Kind = "notification"
+	KindResponse     Kind = "response"
+	KindBatch        Kind = "batch"
+)
+
+// Message classified json rpc message, Request is set for request and notification,
+// Response is set for response and Batch is set for batch
+type Message struct {
+	Kind     Kind
+	Request  *Request
+	Response *Response
+	Batch    []*Message
+}
+
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method *string         `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Parse classify json rpc message, returns ErrFormat if buff is not a json rpc message
+func Parse(buff []byte) (*Message, error) {
+	if isBatch(buff) {
+		var elements []json.RawMessage
+
+		if err := json.Unmarshal(buff, &elements); err != nil {
+			return nil, errors.Wrap(ErrFormat, "unmarshal json rpc batch error: %s", err)
+		}
+
+		if len(elements) == 0 {
+			return nil, errors.Wrap(ErrFormat, "empty json rpc batch")
+		}
+
+		batch := &Message{Kind: KindBatch}
+
+		for i, element := range elements {
+			msg, err := parseOne(element)
+
+			if err != nil {
+				return nil, errors.Wrap(err, "parse json rpc batch element %d error", i)
+			}
+
+			batch.Batch = append(batch.Batch, msg)
+		}
+
+		return batch, nil
+	}
+
+	return parseOne(buff)
+}
+
+func isBatch(buff []byte) bool {
+	buff = bytes.TrimSpace(buff)
+
+	return len(buff) != 0 && buff[0] == '['
+}
+
+func parseOne(buff []byte) (*Message, error) {
+	var msg *message
+
+	if err := json.Unmarshal(buff, &msg); err != nil || msg == nil {
+		return nil, errors.Wrap(ErrFormat, "unmarshal json rpc message error: %s", string(buff))
+	}
+
+	hasResult := len(msg.Result) != 0 || msg.Error != nil
+
+	if msg.ID != nil && !validID(msg.ID, msg.Method == nil) {
+		return nil, errors.Wrap(ErrFormat, "json rpc id must be number or string: %s", string(buff))
+	}
+
+	switch {
+	case msg.Method != nil && hasResult:
+		return nil, errors.Wrap(ErrFormat, "json rpc message has both method and result: %s", string(buff))
+	case msg.Method != nil && *msg.Method == "":
+		return nil, errors.Wrap(ErrFormat, "json rpc request method is empty: %s", string(buff))
+	case msg.Method != nil && msg.ID != nil:
+		return &Message{
+			Kind:    KindRequest,
+			Request: &Request{ID: msg.ID, JSONRPC: "2.0", Method: *msg.Method, Params: msg.Params},
+		}, nil
+	case msg.Method != nil:
+		return &Message{
+			Kind:    KindNotification,
+			Request: &Request{JSONRPC: "2.0", Method: *msg.Method, Params: msg.Params},
+		}, nil
+	case msg.ID != nil || msg.Error != nil:
+		return &Message{
+			Kind:     KindResponse,
+			Response: &Response{ID: msg.ID, JSONRPC: "2.0", Result: msg.Result, Error: msg.Error},
+		}, nil
+	}
+
+	return nil, errors.Wrap(ErrFormat, "expect method or id: %s", string(buff))
+}
+
+// validID check id is json number or string, null is valid response id only
+func validID(id json.RawMessage, response bool) bool {
+	id = bytes.TrimSpace(id)
+
+	if len(id) == 0 {
+		return false
+	}
+
+	switch {
+	case id[0] == '"':
+		var str string
+		return json.Unmarshal(id, &str) == nil
+	case id[0] == '-' || (id[0] >= '0' && id[0] <= '9'):
+		var number json.Number
+		return json.Unmarshal(id, &number) == nil
+	case string(id) == "null":
+		return response
+	}
+
+	return false
+}
+
+// idKey normalize raw id as map key
+func idKey(id json.RawMessage) string {
+	var buff bytes.Buffer
+
+	if err := json.Compact(&buff, id); err != nil {
+		return string(id)
+	}
+
+	return buff.String()
+}
+
+type resultReply struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+}
+
+type errorReply struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Error   *Error          `json:"error"`
+}
+
+// batch replies of one incoming batch, sent as one array after all requests replied or timeout
+type batch struct {
+	replies []json.RawMessage // replies in request order
+	pending int
+	sent    bool
+	timer   *time.Timer
+}
+
+type call struct {
+	ID      int64       `json:"id"`
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// Handler handle incoming request, reply with ReplyRequest or ReplyRequestError so the reply of
+// batch request is collected into the batch reply
+type Handler func(session *Session, request *Request)
+
+type options struct {
+	onRequest      Handler
+	onNotification Handler
+	onResponse     func(session *Session, response *Response)
+	batchTimeout   time.Duration
+}
+
+// Option session option
+type Option func(options *options)
+
+// WithRequestHandler set handler of incoming request, request without handler is replied with method not found error
+func WithRequestHandler(handler Handler) Option {
+	return func(options *options) {
+		options.onRequest = handler
+	}
+}
+
+// WithNotificationHandler set handler of incoming notification
+func WithNotificationHandler(handler Handler) Option {
+	return func(options *options) {
+		options.onNotification = handler
+	}
+}
+
+// WithResponseHandler set handler of response not matching any pending Call
+func WithResponseHandler(handler func(session *Session, response *Response)) Option {
+	return func(options *options) {
+		options.onResponse = handler
+	}
+}
+
+// WithBatchTimeout set max time waiting handlers reply requests of incoming batch, unreplied requests
+// are replied with internal error when timeout, default is 30 seconds, zero waits forever
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.batchTimeout = timeout
+	}
+}
+
+// Session json rpc session, Run must be running to recv Call response
+type Session struct {
+	logger    slf4go.Logger
+	mutex     sync.Mutex
+	options   *options
+	tunnel    tun4go.Tunnel
+	transport tun4go.Transport
+	writeLock sync.Mutex
+	nextID    int64
+	pending   map[string]chan *Response // keyed by idKey
+	err       error
+}
+
+// New create json rpc session over connected tunnel
+func New(tunnel tun4go.Tunnel, transport tun4go.Transport, opts ...Option) *Session {
+	options := &options{
+		batchTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Session{
+		logger:    slf4go.Get("rpc-session"),
+		options:   options,
+		tunnel:    tunnel,
+		transport: transport,
+		nextID:    time.Now().UnixNano() / int64(time.Millisecond) * 1000,
+		pending:   make(map[string]chan *Response),
+	}
+}
+
+func (session *Session) send(v interface{}) error {
+	buff, err := json.Marshal(v)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal json rpc message error")
+	}
+
+	session.writeLock.Lock()
+	defer session.writeLock.Unlock()
+
+	return session.tunnel.Send(buff, session.transport)
+}
+
+// Call send request and wait the response, the response result is unmarshaled into result if not nil.
+// A json rpc error response is returned as *Error
+func (session *Session) Call(method string, params interface{}, result interface{}) error {
+	return session.CallContext(context.Background(), method, params, result)
+}
+
+// CallContext context aware Call, returns ctx.Err() and forgets the request when ctx done
+func (session *Session) CallContext(ctx context.Context, method string, params interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	id := atomic.AddInt64(&session.nextID, 1)
+	key := strconv.FormatInt(id, 10)
+
+	ch := make(chan *Response, 1)
+
+	session.mutex.Lock()
+
+	if session.err != nil {
+		session.mutex.Unlock()
+		return errors.Wrap(ErrClosed, "call %s on closed session", method)
+	}
+
+	session.pending[key] = ch
+
+	session.mutex.Unlock()
+
+	err := session.send(&call{ID: id, JSONRPC: "2.0", Method: method, Params: params})
+
+	if err != nil {
+		session.forget(key)
+		return err
+	}
+
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			return errors.Wrap(session.err, "wait %s response error", method)
+		}
+
+		return unmarshalResult(method, response, result)
+	case <-ctx.Done():
+		session.forget(key)
+		return ctx.Err()
+	}
+}
+
+// forget remove pending calls
+func (session *Session) forget(keys ...string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(session.pending, key)
+	}
+}
+
+func unmarshalResult(method string, response *Response, result interface{}) error {
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if result != nil && len(response.Result) != 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return errors.Wrap(err, "unmarshal %s result error", method)
+		}
+	}
+
+	return nil
+}
+
+// BatchCall one request of CallBatch, Error is set to *Error if peer replies error
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{} // optional result unmarshal target
+	Error  error
+}
+
+// CallBatch send calls as one json rpc batch and wait all responses, the returned error is session error only,
+// the error of each call is set to BatchCall.Error
+func (session *Session) CallBatch(calls []*BatchCall) error {
+	return session.CallBatchContext(context.Background(), calls)
+}
+
+// CallBatchContext context aware CallBatch, returns ctx.Err() and forgets the requests when ctx done
+func (session *Session) CallBatchContext(ctx context.Context, calls []*BatchCall) error {
+	if len(calls) == 0 {
+		return errors.Wrap(ErrFormat, "empty json rpc batch")
+	}
+
+	requests := make([]*call, len(calls))
+	keys := make([]string, len(calls))
+	chs := make([]chan *Response, len(calls))
+
+	session.mutex.Lock()
+
+	if session.err != nil {
+		session.mutex.Unlock()
+		return errors.Wrap(ErrClosed, "call batch on closed session")
+	}
+
+	for i, c := range calls {
+		params := c.Params
+
+		if params == nil {
+			params = []interface{}{}
+		}
+
+		requests[i] = &call{ID: atomic.AddInt64(&session.nextID, 1), JSONRPC: "2.0", Method: c.Method, Params: params}
+		keys[i] = strconv.FormatInt(requests[i].ID, 10)
+		chs[i] = make(chan *Response, 1)
+
+		session.pending[keys[i]] = chs[i]
+	}
+
+	session.mutex.Unlock()
+
+	if err := session.send(requests); err != nil {
+		session.forget(keys...)
+		return err
+	}
+
+	for i, c := range calls {
+		select {
+		case response, ok := <-chs[i]:
+			if !ok {
+				return errors.Wrap(session.err, "wait batch %s response error", c.Method)
+			}
+
+			c.Error = unmarshalResult(c.Method, response, c.Result)
+		case <-ctx.Done():
+			session.forget(keys...)
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Notify send notification, which has no id and expect no response
+func (session *Session) Notify(method string, params interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	return session.send(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// Reply send result response of request id, use ReplyRequest to reply request of incoming batch
+func (session *Session) Reply(id json.RawMessage, result interface{}) error {
+	return session.send(&resultReply{ID: id, JSONRPC: "2.0", Result: result})
+}
+
+// ReplyError send error response of request id, use ReplyRequestError to reply request of incoming batch
+func (session *Session) ReplyError(id json.RawMessage, code int64, msg string) error {
+	return session.send(&errorReply{ID: id, JSONRPC: "2.0", Error: &Error{Code: code, Message: msg}})
+}
+
+// ReplyRequest send result response of request, reply of batch request is sent with the whole batch
+func (session *Session) ReplyRequest(request *Request, result interface{}) error {
+	return session.reply(request, &resultReply{ID: request.ID, JSONRPC: "2.0", Result: result})
+}
+
+// ReplyRequestError send error response of request, reply of batch request is sent with the whole batch
+func (session *Session) ReplyRequestError(request *Request, code int64, msg string) error {
+	return session.reply(request, &errorReply{ID: request.ID, JSONRPC: "2.0", Error: &Error{Code: code, Message: msg}})
+}
+
+func (session *Session) reply(request *Request, reply interface{}) error {
+	if request.batch == nil {
+		return session.send(reply)
+	}
+
+	buff, err := json.Marshal(reply)
+
+	if err != nil {
+		return errors.Wrap(err, "marshal json rpc message error")
+	}
+
+	b := request.batch
+
+	session.mutex.Lock()
+
+	if b.sent {
+		session.mutex.Unlock()
+		return errors.Wrap(ErrTimeout, "reply %s after batch sent", request.Method)
+	}
+
+	// replied twice
+	if b.replies[request.slot] != nil {
+		session.mutex.Unlock()
+		return nil
+	}
+
+	b.replies[request.slot] = buff
+	b.pending--
+
+	done := b.pending == 0
+
+	if done {
+		b.sent = true
+
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+	}
+
+	session.mutex.Unlock()
+
+	if done {
+		return session.send(b.replies)
+	}
+
+	return nil
+}
+
+// expire reply unreplied requests of batch with internal error and send it
+func (session *Session) expire(b *batch, requests []*Request) {
+	session.mutex.Lock()
+
+	if b.sent {
+		session.mutex.Unlock()
+		return
+	}
+
+	b.sent = true
+
+	for _, request := range requests {
+		if b.replies[request.slot] != nil {
+			continue
+		}
+
+		session.logger.W("batch request {@method} reply timeout", request.Method)
+
+		b.replies[request.slot], _ = json.Marshal(&errorReply{
+			ID:      request.ID,
+			JSONRPC: "2.0",
+			Error:   &Error{Code: CodeInternalError, Message: "reply timeout"},
+		})
+	}
+
+	session.mutex.Unlock()
+
+	if err := session.send(b.replies); err != nil {
+		session.logger.W("send expired batch error {@err}", err)
+	}
+}
+
+// Run recv and dispatch msg until tunnel recv error, pending Calls are failed with the returned error
+func (session *Session) Run() error {
+	for {
+		buff, err := session.tunnel.Recv(session.transport)
+
+		if err != nil {
+			session.close(err)
+			return err
+		}
+
+		if err := session.dispatch(buff); err != nil {
+			session.logger.W("dispatch msg error {@err}", err)
+		}
+	}
+}
+
+func (session *Session) close(err error) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.err = err
+
+	for id, ch := range session.pending {
+		close(ch)
+		delete(session.pending, id)
+	}
+}
+
+func (session *Session) dispatch(buff []byte) error {
+	// batch elements are dispatched one by one, an invalid element does not fail the others
+	if isBatch(buff) {
+		return session.dispatchBatch(buff)
+	}
+
+	msg, err := Parse(buff)
+
+	if err != nil {
+		return err
+	}
+
+	switch msg.Kind {
+	case KindRequest:
+		request := msg.Request
+
+		if session.options.onRequest == nil {
+			return session.ReplyError(request.ID, CodeMethodNotFound, fmt.Sprintf("method %s not found", request.Method))
+		}
+
+		session.options.onRequest(session, request)
+
+	case KindNotification:
+		if session.options.onNotification != nil {
+			session.options.onNotification(session, msg.Request)
+		}
+
+	case KindResponse:
+		session.response(msg.Response)
+	}
+
+	return nil
+}
+
+func (session *Session) response(response *Response) {
+	key := idKey(response.ID)
+
+	session.mutex.Lock()
+	ch, ok := session.pending[key]
+	delete(session.pending, key)
+	session.mutex.Unlock()
+
+	if ok {
+		ch <- response
+	} else if session.options.onResponse != nil {
+		session.options.onResponse(session, response)
+	}
+}
+
+// dispatchBatch dispatch each element of batch, replies of requests are collected and sent as one batch,
+// invalid elements are replied with invalid request error
+func (session *Session) dispatchBatch(buff []byte) error {
+	var elements []json.RawMessage
+
+	if err := json.Unmarshal(buff, &elements); err != nil || len(elements) == 0 {
+		return errors.Wrap(ErrFormat, "unmarshal json rpc batch error: %s", string(buff))
+	}
+
+	b := &batch{}
+
+	var requests, notifications []*Request
+
+	for _, element := range elements {
+		msg, err := parseOne(element)
+
+		if err != nil {
+			session.logger.W("invalid batch element {@element}: {@err}", string(element), err)
+
+			buff, _ := json.Marshal(&errorReply{JSONRPC: "2.0", Error: &Error{Code: CodeInvalidRequest, Message: "invalid request"}})
+
+			b.replies = append(b.replies, buff)
+
+			continue
+		}
+
+		switch msg.Kind {
+		case KindRequest:
+			msg.Request.batch = b
+			msg.Request.slot = len(b.replies)
+
+			b.replies = append(b.replies, nil)
+			b.pending++
+
+			requests = append(requests, msg.Request)
+		case KindNotification:
+			notifications = append(notifications, msg.Request)
+		case KindResponse:
+			session.response(msg.Response)
+		}
+	}
+
+	if b.pending != 0 && session.options.batchTimeout > 0 {
+		session.mutex.Lock()
+		b.timer = time.AfterFunc(session.options.batchTimeout, func() {
+			session.expire(b, requests)
+		})
+		session.mutex.Unlock()
+	}
+
+	for _, notification := range notifications {
+		if session.options.onNotification != nil {
+			session.options.onNotification(session, notification)
+		}
+	}
+
+	// only invalid elements need reply
+	if b.pending == 0 && len(b.replies) != 0 {
+		return session.send(b.replies)
+	}
+
+	for _, request := range requests {
+		if session.options.onRequest == nil {
+			if err := session.ReplyRequestError(request, CodeMethodNotFound, fmt.Sprintf("method %s not found", request.Method)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		session.options.onRequest(session, request)
+	}
+
+	return nil
+}
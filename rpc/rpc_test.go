@@ -0,0 +1,407 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+type pipe struct {
+	in  chan []byte
+	out chan []byte
+}
+
+func newPipe() (*pipe, *pipe) {
+	a := make(chan []byte, 16)
+	b := make(chan []byte, 16)
+
+	return &pipe{in: a, out: b}, &pipe{in: b, out: a}
+}
+
+func (p *pipe) Read() ([]byte, error) {
+	buff, ok := <-p.in
+
+	if !ok {
+		return nil, ErrClosed
+	}
+
+	return buff, nil
+}
+
+func (p *pipe) Write(buff []byte) error {
+	p.out <- buff
+	return nil
+}
+
+// plainTunnel pass msg through transport without any encryption
+type plainTunnel struct {
+}
+
+func (tunnel *plainTunnel) Send(msg []byte, transport tun4go.Transport) error {
+	return transport.Write(msg)
+}
+
+func (tunnel *plainTunnel) Recv(transport tun4go.Transport) ([]byte, error) {
+	return transport.Read()
+}
+
+func (tunnel *plainTunnel) Disconnect(transport tun4go.Transport) error { return nil }
+func (tunnel *plainTunnel) Connect(transport tun4go.Transport) error    { return nil }
+func (tunnel *plainTunnel) Context() ([]byte, error)                    { return nil, nil }
+
+func TestCall(t *testing.T) {
+	a, b := newPipe()
+
+	notified := make(chan string, 1)
+
+	server := New(&plainTunnel{}, b,
+		WithRequestHandler(func(session *Session, request *Request) {
+			var params []string
+
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				session.ReplyError(request.ID, CodeInvalidParams, err.Error())
+				return
+			}
+
+			if request.Method == "echo" {
+				session.Reply(request.ID, params[0])
+				return
+			}
+
+			session.ReplyError(request.ID, CodeMethodNotFound, "method not found")
+		}),
+		WithNotificationHandler(func(session *Session, request *Request) {
+			notified <- request.Method
+		}))
+
+	go server.Run()
+
+	client := New(&plainTunnel{}, a)
+
+	go client.Run()
+
+	var result string
+
+	require.NoError(t, client.Call("echo", []string{"hello"}, &result))
+	require.Equal(t, "hello", result)
+
+	err := client.Call("unknown", nil, &result)
+
+	var rpcErr *Error
+
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, CodeMethodNotFound, rpcErr.Code)
+
+	require.NoError(t, client.Notify("ping", nil))
+	require.Equal(t, "ping", <-notified)
+}
+
+func TestUnsolicitedResponse(t *testing.T) {
+	a, b := newPipe()
+
+	responses := make(chan *Response, 1)
+
+	client := New(&plainTunnel{}, a, WithResponseHandler(func(session *Session, response *Response) {
+		responses <- response
+	}))
+
+	go client.Run()
+
+	b.Write([]byte(`{"id":7,"jsonrpc":"2.0","result":"0x1"}`))
+
+	response := <-responses
+
+	require.Equal(t, "7", string(response.ID))
+	require.Equal(t, `"0x1"`, string(response.Result))
+}
+
+func TestClosed(t *testing.T) {
+	a, b := newPipe()
+
+	client := New(&plainTunnel{}, a)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- client.Call("echo", nil, nil)
+	}()
+
+	// drain the request then close the pipe
+	<-b.in
+	close(a.in)
+
+	require.True(t, errors.Is(client.Run(), ErrClosed))
+	require.True(t, errors.Is(<-done, ErrClosed))
+	require.True(t, errors.Is(client.Call("echo", nil, nil), ErrClosed))
+}
+
+func TestParse(t *testing.T) {
+	msg, err := Parse([]byte(`{"id":1,"jsonrpc":"2.0","method":"eth_accounts","params":[]}`))
+
+	require.NoError(t, err)
+	require.Equal(t, KindRequest, msg.Kind)
+	require.Equal(t, "1", string(msg.Request.ID))
+	require.Equal(t, "eth_accounts", msg.Request.Method)
+
+	msg, err = Parse([]byte(`{"jsonrpc":"2.0","method":"accountsChanged","params":[]}`))
+
+	require.NoError(t, err)
+	require.Equal(t, KindNotification, msg.Kind)
+	require.Nil(t, msg.Request.ID)
+
+	msg, err = Parse([]byte(`{"id":2,"jsonrpc":"2.0","result":null}`))
+
+	require.NoError(t, err)
+	require.Equal(t, KindResponse, msg.Kind)
+	require.Equal(t, "2", string(msg.Response.ID))
+
+	msg, err = Parse([]byte(`{"id":null,"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"}}`))
+
+	require.NoError(t, err)
+	require.Equal(t, KindResponse, msg.Kind)
+	require.Equal(t, CodeParseError, msg.Response.Error.Code)
+
+	msg, err = Parse([]byte(` [{"id":3,"method":"eth_chainId"},{"id":4,"result":"0x1"}]`))
+
+	require.NoError(t, err)
+	require.Equal(t, KindBatch, msg.Kind)
+	require.Len(t, msg.Batch, 2)
+	require.Equal(t, KindRequest, msg.Batch[0].Kind)
+	require.Equal(t, KindResponse, msg.Batch[1].Kind)
+
+	for _, buff := range []string{
+		``,
+		`null`,
+		`"eth_accounts"`,
+		`[]`,
+		`[1]`,
+		`{}`,
+		`{"id":1,"method":""}`,
+		`{"id":1,"method":"eth_accounts","result":"0x1"}`,
+		`{"id":{},"method":"eth_accounts"}`,
+		`{"id":true,"method":"eth_accounts"}`,
+		`{"id":null,"method":"eth_accounts"}`,
+	} {
+		_, err := Parse([]byte(buff))
+
+		require.True(t, errors.Is(err, ErrFormat), buff)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	a, b := newPipe()
+
+	notified := make(chan string, 1)
+
+	server := New(&plainTunnel{}, b,
+		WithRequestHandler(func(session *Session, request *Request) {
+			switch request.Method {
+			case "eth_chainId":
+				session.ReplyRequest(request, "0x1")
+			case "eth_accounts":
+				// replies of batch may be sent asynchronously
+				go session.ReplyRequest(request, []string{"0x0"})
+			default:
+				session.ReplyRequestError(request, CodeMethodNotFound, "method not found")
+			}
+		}),
+		WithNotificationHandler(func(session *Session, request *Request) {
+			notified <- request.Method
+		}))
+
+	go server.Run()
+
+	client := New(&plainTunnel{}, a)
+
+	go client.Run()
+
+	var chainID string
+	var accounts []string
+
+	calls := []*BatchCall{
+		{Method: "eth_chainId", Result: &chainID},
+		{Method: "eth_accounts", Result: &accounts},
+		{Method: "eth_unknown"},
+	}
+
+	require.NoError(t, client.CallBatch(calls))
+
+	require.NoError(t, calls[0].Error)
+	require.Equal(t, "0x1", chainID)
+	require.NoError(t, calls[1].Error)
+	require.Equal(t, []string{"0x0"}, accounts)
+
+	var rpcErr *Error
+
+	require.True(t, errors.As(calls[2].Error, &rpcErr))
+	require.Equal(t, CodeMethodNotFound, rpcErr.Code)
+}
+
+func TestBatchReply(t *testing.T) {
+	a, b := newPipe()
+
+	notified := make(chan string, 1)
+
+	server := New(&plainTunnel{}, b,
+		WithRequestHandler(func(session *Session, request *Request) {
+			session.ReplyRequest(request, request.Method)
+		}),
+		WithNotificationHandler(func(session *Session, request *Request) {
+			notified <- request.Method
+		}))
+
+	go server.Run()
+
+	a.Write([]byte(`[
+		{"id":9,"jsonrpc":"2.0","method":"eth_chainId","params":[]},
+		1,
+		{"jsonrpc":"2.0","method":"chainChanged","params":[]},
+		{"id":3,"jsonrpc":"2.0","method":"eth_accounts","params":[]}
+	]`))
+
+	buff, err := a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"id":9,"jsonrpc":"2.0","result":"eth_chainId"},
+		{"id":null,"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"}},
+		{"id":3,"jsonrpc":"2.0","result":"eth_accounts"}
+	]`, string(buff))
+
+	require.Equal(t, "chainChanged", <-notified)
+
+	// batch of notifications only has no reply
+	a.Write([]byte(`[{"jsonrpc":"2.0","method":"chainChanged","params":[]}]`))
+
+	require.Equal(t, "chainChanged", <-notified)
+
+	a.Write([]byte(`[1]`))
+
+	buff, err = a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":null,"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"}}]`, string(buff))
+}
+
+func TestCallContext(t *testing.T) {
+	a, b := newPipe()
+
+	client := New(&plainTunnel{}, a)
+
+	go client.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// peer never answers
+	err := client.CallContext(ctx, "echo", nil, nil)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	<-b.in
+
+	client.mutex.Lock()
+	require.Empty(t, client.pending)
+	client.mutex.Unlock()
+}
+
+func TestStringID(t *testing.T) {
+	a, b := newPipe()
+
+	server := New(&plainTunnel{}, b,
+		WithRequestHandler(func(session *Session, request *Request) {
+			session.Reply(request.ID, request.Method)
+		}))
+
+	go server.Run()
+
+	a.Write([]byte(`{"id":"req-1","jsonrpc":"2.0","method":"eth_chainId","params":[]}`))
+
+	buff, err := a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"req-1","jsonrpc":"2.0","result":"eth_chainId"}`, string(buff))
+}
+
+func TestBatchOverlap(t *testing.T) {
+	a, b := newPipe()
+
+	first := make(chan func(), 1)
+
+	server := New(&plainTunnel{}, b,
+		WithRequestHandler(func(session *Session, request *Request) {
+			var params []string
+
+			json.Unmarshal(request.Params, &params)
+
+			reply := func() { session.ReplyRequest(request, params[0]) }
+
+			// hold the reply of first batch until second batch replied
+			if params[0] == "first" {
+				first <- reply
+				return
+			}
+
+			reply()
+		}))
+
+	go server.Run()
+
+	a.Write([]byte(`[{"id":1,"jsonrpc":"2.0","method":"echo","params":["first"]}]`))
+	a.Write([]byte(`[{"id":1,"jsonrpc":"2.0","method":"echo","params":["second"]}]`))
+
+	buff, err := a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":1,"jsonrpc":"2.0","result":"second"}]`, string(buff))
+
+	(<-first)()
+
+	buff, err = a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":1,"jsonrpc":"2.0","result":"first"}]`, string(buff))
+}
+
+func TestBatchTimeout(t *testing.T) {
+	a, b := newPipe()
+
+	late := make(chan error, 1)
+
+	server := New(&plainTunnel{}, b,
+		WithBatchTimeout(20*time.Millisecond),
+		WithRequestHandler(func(session *Session, request *Request) {
+			if request.Method == "eth_chainId" {
+				session.ReplyRequest(request, "0x1")
+				return
+			}
+
+			// never replied in time
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				late <- session.ReplyRequest(request, "0x0")
+			}()
+		}))
+
+	go server.Run()
+
+	a.Write([]byte(`[
+		{"id":1,"jsonrpc":"2.0","method":"eth_chainId","params":[]},
+		{"id":"2","jsonrpc":"2.0","method":"eth_accounts","params":[]}
+	]`))
+
+	buff, err := a.Read()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"id":1,"jsonrpc":"2.0","result":"0x1"},
+		{"id":"2","jsonrpc":"2.0","error":{"code":-32603,"message":"reply timeout"}}
+	]`, string(buff))
+
+	require.True(t, errors.Is(<-late, ErrTimeout))
+}
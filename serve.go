@@ -0,0 +1,166 @@
+package tun4go
+
+import (
+	"context"
+	"io"
+
+	"github.com/libs4go/errors"
+)
+
+// Handlers Serve callbacks, nil callback is skipped
+type Handlers struct {
+	// OnMessage called with each msg recv from peer
+	OnMessage func(msg []byte)
+
+	// OnStatus called when tunnel status changed, only reported by StatusTunnel
+	OnStatus func(status Status)
+
+	// OnError called with Connect/Recv error, return nil to keep serving or
+	// return error to stop Serve with it. Serve stops with the error if OnError is nil
+	OnError func(err error) error
+}
+
+type server struct {
+	tunnel    Tunnel
+	transport Transport
+	handlers  *Handlers
+	status    Status
+}
+
+// Serve connect tunnel if need and dispatch recv msgs to handlers until ctx done or peer disconnect.
+// ContextTunnel is driven by its context aware functions. Unless both tunnel and transport are context
+// aware, Serve takes ownership of transport: if it implements io.Closer, it is closed when ctx done to
+// break the blocking read, and it can not be reused after Serve returns. Close of such transport must be
+// idempotent, as the caller may close it again. Pass a ContextTunnel with WithContext(transport) to keep
+// the transport open after ctx done. Serve returns nil when peer disconnect, and ctx.Err() when ctx done.
+// Other status than Connecting and Connected, e.g. wc PeerLost, stops Serve with the Recv error
+// whatever OnError returns, as the tunnel can not recv any more before it is resumed
+func Serve(ctx context.Context, tunnel Tunnel, transport Transport, handlers *Handlers) error {
+	if handlers == nil {
+		handlers = &Handlers{}
+	}
+
+	server := &server{
+		tunnel:    tunnel,
+		transport: transport,
+		handlers:  handlers,
+	}
+
+	_, ctxTunnel := tunnel.(ContextTunnel)
+	_, ctxTransport := transport.(ContextTransport)
+
+	if closer, ok := transport.(io.Closer); ok && !(ctxTunnel && ctxTransport) {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				closer.Close()
+			case <-stop:
+			}
+		}()
+	}
+
+	return server.run(ctx)
+}
+
+func (server *server) run(ctx context.Context) error {
+	for {
+		if err := server.connect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err := server.error(err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	server.checkStatus()
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		msg, err := server.recv(ctx)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		switch status := server.checkStatus(); status {
+		case Connecting, Connected:
+		case Disconnecting, Disconnected:
+			return nil
+		default:
+			if err == nil {
+				err = errors.Wrap(ErrStatus, "tunnel status %s", status)
+			}
+
+			return err
+		}
+
+		if err != nil {
+			if err := server.error(err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if server.handlers.OnMessage != nil {
+			server.handlers.OnMessage(msg)
+		}
+	}
+}
+
+func (server *server) connect(ctx context.Context) error {
+	if tunnel, ok := server.tunnel.(ContextTunnel); ok {
+		return tunnel.ConnectContext(ctx, server.transport)
+	}
+
+	return server.tunnel.Connect(server.transport)
+}
+
+func (server *server) recv(ctx context.Context) ([]byte, error) {
+	if tunnel, ok := server.tunnel.(ContextTunnel); ok {
+		return tunnel.RecvContext(ctx, server.transport)
+	}
+
+	return server.tunnel.Recv(server.transport)
+}
+
+func (server *server) error(err error) error {
+	if server.handlers.OnError == nil {
+		return err
+	}
+
+	return server.handlers.OnError(err)
+}
+
+func (server *server) checkStatus() Status {
+	statusTunnel, ok := server.tunnel.(StatusTunnel)
+
+	if !ok {
+		return Connected
+	}
+
+	status := statusTunnel.Status()
+
+	if status != server.status {
+		server.status = status
+
+		if server.handlers.OnStatus != nil {
+			server.handlers.OnStatus(status)
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,207 @@
+package tun4go
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test error")
+var errLost = errors.New("peer lost")
+
+type chanTransport struct {
+	ch     chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newChanTransport() *chanTransport {
+	return &chanTransport{
+		ch:     make(chan []byte, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (transport *chanTransport) Read() ([]byte, error) {
+	select {
+	case buff := <-transport.ch:
+		return buff, nil
+	case <-transport.closed:
+		return nil, errTest
+	}
+}
+
+func (transport *chanTransport) Write(buff []byte) error {
+	transport.ch <- buff
+	return nil
+}
+
+func (transport *chanTransport) Close() error {
+	transport.once.Do(func() { close(transport.closed) })
+	return nil
+}
+
+// mockTunnel treat "bye" as peer disconnect msg, "bad" as malformed msg and "lost" as peer lost
+type mockTunnel struct {
+	status Status
+}
+
+func (tunnel *mockTunnel) Send(msg []byte, transport Transport) error {
+	return transport.Write(msg)
+}
+
+func (tunnel *mockTunnel) Recv(transport Transport) ([]byte, error) {
+	buff, err := transport.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch string(buff) {
+	case "bye":
+		tunnel.status = Disconnected
+		return nil, errTest
+	case "bad":
+		return nil, errTest
+	case "lost":
+		tunnel.status = "peer-lost"
+		return nil, errLost
+	}
+
+	return buff, nil
+}
+
+func (tunnel *mockTunnel) Disconnect(transport Transport) error { return nil }
+
+func (tunnel *mockTunnel) Connect(transport Transport) error {
+	tunnel.status = Connected
+	return nil
+}
+
+func (tunnel *mockTunnel) Context() ([]byte, error) { return nil, nil }
+
+func (tunnel *mockTunnel) Status() Status { return tunnel.status }
+
+func TestServe(t *testing.T) {
+	transport := newChanTransport()
+
+	tunnel := &mockTunnel{status: Disconnected}
+
+	var msgs []string
+	var status []Status
+	var errs []error
+
+	transport.Write([]byte("hello"))
+	transport.Write([]byte("bad"))
+	transport.Write([]byte("world"))
+	transport.Write([]byte("bye"))
+
+	err := Serve(context.Background(), tunnel, transport, &Handlers{
+		OnMessage: func(msg []byte) { msgs = append(msgs, string(msg)) },
+		OnStatus:  func(s Status) { status = append(status, s) },
+		OnError: func(err error) error {
+			errs = append(errs, err)
+			return nil
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello", "world"}, msgs)
+	require.Equal(t, []Status{Connected, Disconnected}, status)
+	require.Len(t, errs, 1)
+}
+
+func TestServePeerLost(t *testing.T) {
+	transport := newChanTransport()
+
+	transport.Write([]byte("lost"))
+	transport.Write([]byte("hello"))
+
+	var status []Status
+
+	// peer lost stops Serve even if OnError keeps serving
+	err := Serve(context.Background(), &mockTunnel{}, transport, &Handlers{
+		OnStatus: func(s Status) { status = append(status, s) },
+		OnError:  func(err error) error { return nil },
+	})
+
+	require.Equal(t, errLost, err)
+	require.Equal(t, []Status{Connected, "peer-lost"}, status)
+}
+
+func TestServeError(t *testing.T) {
+	transport := newChanTransport()
+
+	transport.Write([]byte("bad"))
+
+	err := Serve(context.Background(), &mockTunnel{}, transport, nil)
+
+	require.Equal(t, errTest, err)
+}
+
+func TestServeCancel(t *testing.T) {
+	transport := newChanTransport()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := Serve(ctx, &mockTunnel{}, transport, nil)
+
+	require.Equal(t, context.Canceled, err)
+
+	// Serve owns the transport and closes it to break the blocking read, closing it again is safe
+	_, err = transport.Read()
+
+	require.Equal(t, errTest, err)
+	require.NoError(t, transport.Close())
+}
+
+// mockContextTunnel context aware mockTunnel
+type mockContextTunnel struct {
+	mockTunnel
+}
+
+func (tunnel *mockContextTunnel) SendContext(ctx context.Context, msg []byte, transport Transport) error {
+	return WriteContext(ctx, transport, msg)
+}
+
+func (tunnel *mockContextTunnel) RecvContext(ctx context.Context, transport Transport) ([]byte, error) {
+	return ReadContext(ctx, transport)
+}
+
+func (tunnel *mockContextTunnel) DisconnectContext(ctx context.Context, transport Transport) error {
+	return nil
+}
+
+func (tunnel *mockContextTunnel) ConnectContext(ctx context.Context, transport Transport) error {
+	return tunnel.Connect(transport)
+}
+
+func TestServeContextTransport(t *testing.T) {
+	underlying := newChanTransport()
+
+	transport := WithContext(underlying)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := Serve(ctx, &mockContextTunnel{}, transport, nil)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// context aware tunnel and transport are left open
+	require.NoError(t, transport.WriteContext(context.Background(), []byte("hello")))
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+}
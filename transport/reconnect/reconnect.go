@@ -0,0 +1,488 @@
+// Package reconnect implement tun4go.Transport decorator which redial the underlying transport
+// when it drops, replay topic subscriptions and buffer outgoing frames while disconnected
+package reconnect
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+	"github.com/libs4go/tun4go"
+)
+
+const errVendor = "reconnect"
+
+// errors
+var (
+	ErrClosed     = errors.New("transport closed", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrBufferFull = errors.New("write buffer full", errors.WithCode(-2), errors.WithVendor(errVendor))
+	ErrGiveUp     = errors.New("redial attempts exhausted", errors.WithCode(-3), errors.WithVendor(errVendor))
+)
+
+// State connection state of reconnecting transport
+type State int
+
+// States
+const (
+	Connected State = iota
+	Disconnected
+	Closed
+)
+
+func (state State) String() string {
+	switch state {
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	case Closed:
+		return "closed"
+	}
+
+	return "unknown"
+}
+
+// Dialer create new underlying transport
+type Dialer func() (tun4go.Transport, error)
+
+// Subscription return the topic if frame is a subscription frame which must be replayed after redial
+type Subscription func(buff []byte) (string, bool)
+
+// StateListener called on every connection state change, err is the cause of Disconnected or Closed
+type StateListener func(state State, err error)
+
+// JSONSubscription match json frames with type "sub", e.g. wc bridge and wc2 relay frames
+func JSONSubscription(buff []byte) (string, bool) {
+	var frame struct {
+		Topic string `json:"topic"`
+		Type  string `json:"type"`
+	}
+
+	if err := json.Unmarshal(buff, &frame); err != nil {
+		return "", false
+	}
+
+	if frame.Type != "sub" || frame.Topic == "" {
+		return "", false
+	}
+
+	return frame.Topic, true
+}
+
+type options struct {
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	jitter       float64
+	maxAttempts  int
+	bufferLimit  int
+	subscription Subscription
+	listener     StateListener
+}
+
+// Option reconnecting transport option
+type Option func(options *options)
+
+// WithBackoff set redial delay, doubled on every failed attempt from min up to max
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(options *options) {
+		options.minBackoff = min
+		options.maxBackoff = max
+	}
+}
+
+// WithJitter randomize redial delay by up to factor (0~1) of it
+func WithJitter(factor float64) Option {
+	return func(options *options) {
+		options.jitter = factor
+	}
+}
+
+// WithMaxAttempts give up after n failed redial attempts, zero means retry forever
+func WithMaxAttempts(n int) Option {
+	return func(options *options) {
+		options.maxAttempts = n
+	}
+}
+
+// WithBufferLimit set max number of frames buffered while disconnected
+func WithBufferLimit(n int) Option {
+	return func(options *options) {
+		options.bufferLimit = n
+	}
+}
+
+// WithSubscription set subscription frame matcher, default is JSONSubscription
+func WithSubscription(subscription Subscription) Option {
+	return func(options *options) {
+		options.subscription = subscription
+	}
+}
+
+// WithStateListener set connection state listener
+func WithStateListener(listener StateListener) Option {
+	return func(options *options) {
+		options.listener = listener
+	}
+}
+
+// Transport reconnecting transport, safe for one reader and concurrent writers
+type Transport struct {
+	logger  slf4go.Logger
+	options *options
+	dial    Dialer
+	mutex   sync.Mutex
+	conn    tun4go.Transport
+	state   State
+	err     error
+	ready   chan struct{}
+	topics  []string
+	subs    map[string][]byte
+	buffer  [][]byte
+	// serialize listener calls so states are reported in order
+	notify sync.Mutex
+}
+
+// New dial the first underlying transport and return reconnecting transport wrap it
+func New(dial Dialer, opts ...Option) (*Transport, error) {
+	options := &options{
+		minBackoff:   500 * time.Millisecond,
+		maxBackoff:   30 * time.Second,
+		jitter:       0.2,
+		bufferLimit:  128,
+		subscription: JSONSubscription,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	conn, err := dial()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "dial underlying transport error")
+	}
+
+	transport := &Transport{
+		logger:  slf4go.Get("reconnect"),
+		options: options,
+		dial:    dial,
+		conn:    conn,
+		state:   Connected,
+		ready:   make(chan struct{}),
+		subs:    make(map[string][]byte),
+	}
+
+	close(transport.ready)
+
+	transport.changed(Connected, nil)
+
+	return transport, nil
+}
+
+// State return current connection state
+func (transport *Transport) State() State {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	return transport.state
+}
+
+// Read read next frame, block while redialing
+func (transport *Transport) Read() ([]byte, error) {
+	for {
+		conn, err := transport.current()
+
+		if err != nil {
+			return nil, err
+		}
+
+		buff, err := conn.Read()
+
+		if err == nil {
+			return buff, nil
+		}
+
+		transport.broken(conn, err)
+	}
+}
+
+// Write write frame to underlying transport, buffer it while disconnected
+func (transport *Transport) Write(buff []byte) error {
+	transport.mutex.Lock()
+
+	topic, sub := transport.options.subscription(buff)
+
+	if sub {
+		transport.subscribe(topic, buff)
+	}
+
+	switch transport.state {
+	case Closed:
+		err := transport.err
+		transport.mutex.Unlock()
+		return err
+	case Disconnected:
+		defer transport.mutex.Unlock()
+
+		// subscriptions are replayed after redial
+		if sub {
+			return nil
+		}
+
+		if len(transport.buffer) >= transport.options.bufferLimit {
+			return errors.Wrap(ErrBufferFull, "buffered %d frames while disconnected", len(transport.buffer))
+		}
+
+		transport.buffer = append(transport.buffer, buff)
+
+		return nil
+	}
+
+	conn := transport.conn
+
+	transport.mutex.Unlock()
+
+	if err := conn.Write(buff); err != nil {
+		transport.broken(conn, err)
+
+		// buffer it or write to the redialed conn
+		return transport.Write(buff)
+	}
+
+	return nil
+}
+
+// Close close underlying transport and stop redialing
+func (transport *Transport) Close() error {
+	transport.mutex.Lock()
+
+	if transport.state == Closed {
+		transport.mutex.Unlock()
+		return nil
+	}
+
+	conn := transport.conn
+
+	transport.close(errors.Wrap(ErrClosed, "transport closed"))
+
+	transport.mutex.Unlock()
+
+	transport.changed(Closed, nil)
+
+	if conn != nil {
+		return closeTransport(conn)
+	}
+
+	return nil
+}
+
+func (transport *Transport) subscribe(topic string, buff []byte) {
+	if _, ok := transport.subs[topic]; !ok {
+		transport.topics = append(transport.topics, topic)
+	}
+
+	transport.subs[topic] = buff
+}
+
+func (transport *Transport) current() (tun4go.Transport, error) {
+	for {
+		transport.mutex.Lock()
+
+		switch transport.state {
+		case Connected:
+			conn := transport.conn
+			transport.mutex.Unlock()
+			return conn, nil
+		case Closed:
+			err := transport.err
+			transport.mutex.Unlock()
+			return nil, err
+		}
+
+		ready := transport.ready
+
+		transport.mutex.Unlock()
+
+		<-ready
+	}
+}
+
+// close must be called with mutex held
+func (transport *Transport) close(err error) {
+	transport.state = Closed
+	transport.err = err
+	transport.conn = nil
+	transport.buffer = nil
+
+	select {
+	case <-transport.ready:
+	default:
+		close(transport.ready)
+	}
+}
+
+func (transport *Transport) broken(conn tun4go.Transport, err error) {
+	transport.mutex.Lock()
+
+	// another caller already reported this connection
+	if transport.state != Connected || transport.conn != conn {
+		transport.mutex.Unlock()
+		return
+	}
+
+	transport.logger.W("underlying transport broken: {@err}", err)
+
+	transport.state = Disconnected
+	transport.conn = nil
+	transport.ready = make(chan struct{})
+
+	transport.mutex.Unlock()
+
+	closeTransport(conn)
+
+	transport.changed(Disconnected, err)
+
+	go transport.redial()
+}
+
+func (transport *Transport) redial() {
+	delay := transport.options.minBackoff
+
+	for attempt := 1; ; attempt++ {
+		time.Sleep(transport.jitter(delay))
+
+		if transport.State() == Closed {
+			return
+		}
+
+		conn, err := transport.dial()
+
+		if err == nil {
+			if err = transport.replay(conn); err == nil {
+				return
+			}
+		}
+
+		transport.logger.W("redial attempt {@attempt} error: {@err}", attempt, err)
+
+		if transport.options.maxAttempts > 0 && attempt >= transport.options.maxAttempts {
+			transport.mutex.Lock()
+
+			if transport.state == Closed {
+				transport.mutex.Unlock()
+				return
+			}
+
+			cause := errors.Wrap(ErrGiveUp, "give up after %d redial attempts", attempt)
+
+			transport.close(cause)
+
+			transport.mutex.Unlock()
+
+			transport.changed(Closed, cause)
+
+			return
+		}
+
+		if delay *= 2; delay > transport.options.maxBackoff {
+			delay = transport.options.maxBackoff
+		}
+	}
+}
+
+// replay write subscriptions and buffered frames to new conn, then make it current,
+// frames written meanwhile are buffered and flushed before switching
+func (transport *Transport) replay(conn tun4go.Transport) error {
+	replayed := 0
+
+	transport.mutex.Lock()
+
+	for transport.state != Closed && (replayed < len(transport.topics) || len(transport.buffer) > 0) {
+		var subs [][]byte
+
+		for _, topic := range transport.topics[replayed:] {
+			subs = append(subs, transport.subs[topic])
+		}
+
+		buffer := transport.buffer
+
+		transport.buffer = nil
+
+		transport.mutex.Unlock()
+
+		for _, buff := range subs {
+			if err := conn.Write(buff); err != nil {
+				return transport.requeue(conn, buffer, err)
+			}
+
+			replayed++
+		}
+
+		for i, buff := range buffer {
+			if err := conn.Write(buff); err != nil {
+				return transport.requeue(conn, buffer[i:], err)
+			}
+		}
+
+		transport.mutex.Lock()
+	}
+
+	if transport.state == Closed {
+		transport.mutex.Unlock()
+		closeTransport(conn)
+		return nil
+	}
+
+	transport.conn = conn
+	transport.state = Connected
+
+	close(transport.ready)
+
+	transport.mutex.Unlock()
+
+	transport.changed(Connected, nil)
+
+	return nil
+}
+
+// requeue put frames not yet written back to the front of buffer
+func (transport *Transport) requeue(conn tun4go.Transport, frames [][]byte, err error) error {
+	closeTransport(conn)
+
+	transport.mutex.Lock()
+	transport.buffer = append(frames, transport.buffer...)
+	transport.mutex.Unlock()
+
+	return errors.Wrap(err, "replay frames error")
+}
+
+func (transport *Transport) jitter(delay time.Duration) time.Duration {
+	if transport.options.jitter <= 0 || delay <= 0 {
+		return delay
+	}
+
+	return delay - time.Duration(rand.Float64()*transport.options.jitter*float64(delay))
+}
+
+func (transport *Transport) changed(state State, err error) {
+	if transport.options.listener == nil {
+		return
+	}
+
+	transport.notify.Lock()
+	defer transport.notify.Unlock()
+
+	transport.options.listener(state, err)
+}
+
+func closeTransport(conn tun4go.Transport) error {
+	if closer, ok := conn.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package reconnect
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+var errDrop = errors.New("connection dropped")
+
+type fakeConn struct {
+	sync.Mutex
+	inbox  chan []byte
+	closed chan struct{}
+	once   sync.Once
+	writes []string
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		inbox:  make(chan []byte, 10),
+		closed: make(chan struct{}),
+	}
+}
+
+func (conn *fakeConn) Read() ([]byte, error) {
+	select {
+	case buff := <-conn.inbox:
+		return buff, nil
+	case <-conn.closed:
+		return nil, errDrop
+	}
+}
+
+func (conn *fakeConn) Write(buff []byte) error {
+	select {
+	case <-conn.closed:
+		return errDrop
+	default:
+	}
+
+	conn.Lock()
+	defer conn.Unlock()
+
+	conn.writes = append(conn.writes, string(buff))
+
+	return nil
+}
+
+func (conn *fakeConn) Close() error {
+	conn.once.Do(func() { close(conn.closed) })
+	return nil
+}
+
+func (conn *fakeConn) Writes() []string {
+	conn.Lock()
+	defer conn.Unlock()
+
+	return append([]string(nil), conn.writes...)
+}
+
+type fakeDialer struct {
+	sync.Mutex
+	conns []*fakeConn
+	fails int
+}
+
+func (dialer *fakeDialer) Dial() (tun4go.Transport, error) {
+	dialer.Lock()
+	defer dialer.Unlock()
+
+	if dialer.fails > 0 {
+		dialer.fails--
+		return nil, errDrop
+	}
+
+	conn := newFakeConn()
+
+	dialer.conns = append(dialer.conns, conn)
+
+	return conn, nil
+}
+
+func (dialer *fakeDialer) Conn(i int) *fakeConn {
+	dialer.Lock()
+	defer dialer.Unlock()
+
+	if i >= len(dialer.conns) {
+		return nil
+	}
+
+	return dialer.conns[i]
+}
+
+type stateRecorder struct {
+	sync.Mutex
+	states []State
+}
+
+func (recorder *stateRecorder) Listen(state State, err error) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	recorder.states = append(recorder.states, state)
+}
+
+func (recorder *stateRecorder) States() []State {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	return append([]State(nil), recorder.states...)
+}
+
+func TestReconnect(t *testing.T) {
+	require := require.New(t)
+
+	dialer := &fakeDialer{}
+	recorder := &stateRecorder{}
+
+	transport, err := New(dialer.Dial,
+		WithBackoff(time.Millisecond, 5*time.Millisecond),
+		WithStateListener(recorder.Listen))
+
+	require.NoError(err)
+
+	sub1 := `{"topic":"a","type":"sub","payload":""}`
+	sub2 := `{"topic":"b","type":"sub","payload":""}`
+	pub := `{"topic":"b","type":"pub","payload":"1"}`
+
+	require.NoError(transport.Write([]byte(sub1)))
+	require.NoError(transport.Write([]byte(sub2)))
+	require.NoError(transport.Write([]byte(sub1)))
+
+	dialer.fails = 2
+
+	dialer.Conn(0).Close()
+
+	// write detect the broken conn and buffer the frame
+	require.NoError(transport.Write([]byte(pub)))
+
+	go func() {
+		for dialer.Conn(1) == nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		dialer.Conn(1).inbox <- []byte("hello")
+	}()
+
+	buff, err := transport.Read()
+
+	require.NoError(err)
+	require.Equal("hello", string(buff))
+
+	require.Equal([]string{sub1, sub2, pub}, dialer.Conn(1).Writes())
+
+	require.NoError(transport.Close())
+
+	_, err = transport.Read()
+	require.True(errors.Is(err, ErrClosed))
+	require.True(errors.Is(transport.Write([]byte(pub)), ErrClosed))
+
+	require.Equal([]State{Connected, Disconnected, Connected, Closed}, recorder.States())
+}
+
+func TestBufferLimit(t *testing.T) {
+	require := require.New(t)
+
+	dialer := &fakeDialer{}
+
+	transport, err := New(dialer.Dial, WithBackoff(time.Hour, time.Hour), WithBufferLimit(1))
+
+	require.NoError(err)
+
+	defer transport.Close()
+
+	dialer.Conn(0).Close()
+
+	require.NoError(transport.Write([]byte("1")))
+	require.Equal(Disconnected, transport.State())
+
+	require.True(errors.Is(transport.Write([]byte("2")), ErrBufferFull))
+
+	// subscriptions are not buffered
+	require.NoError(transport.Write([]byte(`{"topic":"a","type":"sub"}`)))
+}
+
+func TestGiveUp(t *testing.T) {
+	require := require.New(t)
+
+	dialer := &fakeDialer{fails: 10}
+	recorder := &stateRecorder{}
+
+	transport, err := New(func() (tun4go.Transport, error) {
+		return newFakeConn(), nil
+	}, WithBackoff(time.Millisecond, time.Millisecond), WithMaxAttempts(3), WithStateListener(recorder.Listen))
+
+	require.NoError(err)
+
+	transport.dial = dialer.Dial
+
+	transport.broken(transport.conn, errDrop)
+
+	_, err = transport.Read()
+
+	require.True(errors.Is(err, ErrGiveUp))
+	require.Equal([]State{Connected, Disconnected, Closed}, recorder.States())
+}
@@ -0,0 +1,341 @@
+// Package ws implement tun4go.Transport over websocket, used to talk with wc bridge server
+package ws
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/libs4go/errors"
+	"github.com/libs4go/slf4go"
+)
+
+const errVendor = "ws"
+
+// errors
+var (
+	ErrClosed    = errors.New("transport closed", errors.WithCode(-1), errors.WithVendor(errVendor))
+	ErrQueueFull = errors.New("read queue full", errors.WithCode(-2), errors.WithVendor(errVendor))
+)
+
+// Transport websocket transport, implements tun4go.ContextTransport
+type Transport struct {
+	logger      slf4go.Logger
+	conn        *websocket.Conn
+	writeLock   sync.Mutex
+	closeOnce   sync.Once
+	closed      chan struct{}
+	messageType int
+	pongTimeout time.Duration
+	queueLimit  int
+	readLock    sync.Mutex
+	frames      [][]byte      // frames read by background reader
+	err         error         // terminal read error
+	signal      chan struct{} // notify frame or error arrived
+}
+
+type options struct {
+	dialer       websocket.Dialer
+	header       http.Header
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	binary       bool
+	queueLimit   int
+}
+
+// Option websocket transport dial option
+type Option func(options *options)
+
+// WithTLSConfig set tls config used by wss dial
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *options) {
+		options.dialer.TLSClientConfig = config
+	}
+}
+
+// WithProxy set http proxy function, default is http.ProxyFromEnvironment
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(options *options) {
+		options.dialer.Proxy = proxy
+	}
+}
+
+// WithHeader set handshake request header
+func WithHeader(header http.Header) Option {
+	return func(options *options) {
+		options.header = header
+	}
+}
+
+// WithHandshakeTimeout set websocket handshake timeout
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.dialer.HandshakeTimeout = timeout
+	}
+}
+
+// WithPing send ping every interval and close connection if no pong recv in timeout,
+// zero interval disable ping
+func WithPing(interval time.Duration, timeout time.Duration) Option {
+	return func(options *options) {
+		options.pingInterval = interval
+		options.pongTimeout = timeout
+	}
+}
+
+// WithBinary write msg as binary frame instead of text frame
+func WithBinary() Option {
+	return func(options *options) {
+		options.binary = true
+	}
+}
+
+// WithQueueLimit set max number of frames read but not yet consumed by Read, default is 1024,
+// the transport is closed with ErrQueueFull when a frame arrives at a full queue
+func WithQueueLimit(limit int) Option {
+	return func(options *options) {
+		options.queueLimit = limit
+	}
+}
+
+// BridgeURL convert http/https bridge url to ws/wss url
+func BridgeURL(bridge string) string {
+	if strings.HasPrefix(bridge, "http://") {
+		return "ws" + strings.TrimPrefix(bridge, "http")
+	} else if strings.HasPrefix(bridge, "https://") {
+		return "wss" + strings.TrimPrefix(bridge, "https")
+	}
+
+	return bridge
+}
+
+// Dial dial to bridge server and create websocket transport
+func Dial(bridge string, opts ...Option) (*Transport, error) {
+	options := &options{
+		dialer: websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 45 * time.Second,
+		},
+		pingInterval: 30 * time.Second,
+		pongTimeout:  60 * time.Second,
+		queueLimit:   1024,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	conn, _, err := options.dialer.Dial(BridgeURL(bridge), options.header)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "dial to websocket server %s error", bridge)
+	}
+
+	transport := &Transport{
+		logger:      slf4go.Get("websocket"),
+		conn:        conn,
+		closed:      make(chan struct{}),
+		messageType: websocket.TextMessage,
+		queueLimit:  options.queueLimit,
+		signal:      make(chan struct{}, 1),
+	}
+
+	if options.binary {
+		transport.messageType = websocket.BinaryMessage
+	}
+
+	if options.pingInterval > 0 {
+		transport.pongTimeout = options.pongTimeout
+
+		conn.SetReadDeadline(time.Now().Add(options.pongTimeout))
+
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(options.pongTimeout))
+		})
+
+		go transport.pingLoop(options.pingInterval)
+	}
+
+	// keep reading so pong and close frames are handled even if nobody calls Read
+	go transport.readLoop()
+
+	return transport, nil
+}
+
+func (transport *Transport) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// WriteControl is safe to call concurrently with writers
+			err := transport.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval))
+
+			if err != nil {
+				transport.logger.W("send ping error {@err}", err)
+				return
+			}
+		case <-transport.closed:
+			return
+		}
+	}
+}
+
+// Read read next text or binary frame
+func (transport *Transport) Read() ([]byte, error) {
+	return transport.ReadContext(context.Background())
+}
+
+// ReadContext read next text or binary frame or return ctx.Err() when ctx done.
+// Frames are read by one background goroutine and queued, because a websocket read interrupted
+// by deadline breaks the connection, so frame arrived after ctx done is kept for next read
+func (transport *Transport) ReadContext(ctx context.Context) ([]byte, error) {
+	for {
+		transport.readLock.Lock()
+
+		if len(transport.frames) != 0 {
+			buff := transport.frames[0]
+			transport.frames = transport.frames[1:]
+			transport.readLock.Unlock()
+			return buff, nil
+		}
+
+		err := transport.err
+
+		transport.readLock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-transport.signal:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (transport *Transport) readLoop() {
+	for {
+		buff, err := transport.readFrame()
+
+		transport.readLock.Lock()
+
+		if err == nil && len(transport.frames) >= transport.queueLimit {
+			err = errors.Wrap(ErrQueueFull, "%d frames queued", len(transport.frames))
+		}
+
+		if err != nil {
+			transport.err = err
+		} else {
+			transport.frames = append(transport.frames, buff)
+		}
+
+		transport.readLock.Unlock()
+
+		select {
+		case transport.signal <- struct{}{}:
+		default:
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrQueueFull) {
+				transport.logger.E("close websocket, {@err}", err)
+				transport.Close()
+			}
+
+			return
+		}
+	}
+}
+
+func (transport *Transport) readFrame() ([]byte, error) {
+	for {
+		t, message, err := transport.conn.ReadMessage()
+
+		if err != nil {
+			select {
+			case <-transport.closed:
+				return nil, errors.Wrap(ErrClosed, "read from closed websocket")
+			default:
+			}
+
+			return nil, errors.Wrap(err, "read from websocket error")
+		}
+
+		if transport.pongTimeout > 0 {
+			transport.conn.SetReadDeadline(time.Now().Add(transport.pongTimeout))
+		}
+
+		if t != websocket.TextMessage && t != websocket.BinaryMessage {
+			continue
+		}
+
+		transport.logger.D("Recv msg: {@msg}", string(message))
+
+		return message, nil
+	}
+}
+
+// Write write msg as one frame
+func (transport *Transport) Write(buff []byte) error {
+	return transport.WriteContext(context.Background(), buff)
+}
+
+// WriteContext write msg as one frame, ctx deadline is used as write deadline
+func (transport *Transport) WriteContext(ctx context.Context, buff []byte) error {
+
+	transport.logger.D("Send msg: {@msg}", string(buff))
+
+	transport.writeLock.Lock()
+	defer transport.writeLock.Unlock()
+
+	select {
+	case <-transport.closed:
+		return errors.Wrap(ErrClosed, "write to closed websocket")
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+
+	transport.conn.SetWriteDeadline(deadline)
+
+	err := transport.conn.WriteMessage(transport.messageType, buff)
+
+	if err != nil {
+		return errors.Wrap(err, "websocket send message error")
+	}
+
+	return nil
+}
+
+// Close send close frame and close underlying connection, later calls are no-op.
+// Close does not wait for pending writes, a write blocked on the connection fails once it is closed
+func (transport *Transport) Close() error {
+	var err error
+
+	transport.closeOnce.Do(func() {
+		close(transport.closed)
+		// WriteControl is safe to call concurrently with a blocked writer, it gives up at the deadline
+		transport.conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(time.Second))
+
+		err = transport.conn.Close()
+	})
+
+	return err
+}
@@ -0,0 +1,288 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/libs4go/errors"
+	"github.com/libs4go/tun4go"
+	"github.com/stretchr/testify/require"
+)
+
+func newEchoServer(tls bool) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		for {
+			t, message, err := conn.ReadMessage()
+
+			if err != nil {
+				return
+			}
+
+			if err := conn.WriteMessage(t, message); err != nil {
+				return
+			}
+		}
+	})
+
+	if tls {
+		return httptest.NewTLSServer(handler)
+	}
+
+	return httptest.NewServer(handler)
+}
+
+func TestBridgeURL(t *testing.T) {
+	require.Equal(t, "wss://bridge.walletconnect.org", BridgeURL("https://bridge.walletconnect.org"))
+	require.Equal(t, "ws://127.0.0.1:8080/", BridgeURL("http://127.0.0.1:8080/"))
+	require.Equal(t, "ws://127.0.0.1:8080", BridgeURL("ws://127.0.0.1:8080"))
+}
+
+func TestEcho(t *testing.T) {
+	server := newEchoServer(false)
+	defer server.Close()
+
+	transport, err := Dial(server.URL, WithPing(10*time.Millisecond, 50*time.Millisecond))
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	require.NoError(t, transport.Write([]byte("hello")))
+
+	// wait several pong timeout, read deadline must be extended by pong
+	time.Sleep(200 * time.Millisecond)
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+}
+
+func TestBinary(t *testing.T) {
+	server := newEchoServer(false)
+	defer server.Close()
+
+	transport, err := Dial(server.URL, WithBinary())
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	require.NoError(t, transport.Write([]byte{0x00, 0x01, 0xff}))
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x01, 0xff}, buff)
+}
+
+func TestTLS(t *testing.T) {
+	server := newEchoServer(true)
+	defer server.Close()
+
+	_, err := Dial(server.URL)
+
+	require.Error(t, err)
+
+	transport, err := Dial(server.URL, WithTLSConfig(server.Client().Transport.(*http.Transport).TLSClientConfig))
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	require.NoError(t, transport.Write([]byte("hello")))
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+}
+
+func TestClose(t *testing.T) {
+	server := newEchoServer(false)
+	defer server.Close()
+
+	transport, err := Dial(server.URL)
+
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		transport.Close()
+	}()
+
+	_, err = transport.Read()
+
+	require.True(t, errors.Is(err, ErrClosed))
+
+	require.True(t, errors.Is(transport.Write([]byte("hello")), ErrClosed))
+
+	require.NoError(t, transport.Close())
+}
+
+func TestPongTimeout(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// peer never reads, so never answers ping with pong
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		<-release
+	}))
+	defer server.Close()
+
+	transport, err := Dial(server.URL, WithPing(10*time.Millisecond, 50*time.Millisecond))
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	start := time.Now()
+
+	_, err = transport.Read()
+
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrClosed))
+	require.True(t, time.Since(start) < time.Second)
+}
+
+func TestReadContext(t *testing.T) {
+	server := newEchoServer(false)
+	defer server.Close()
+
+	transport, err := Dial(server.URL)
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.ReadContext(ctx)
+
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// cancelled read does not break the connection
+	require.NoError(t, transport.WriteContext(context.Background(), []byte("hello")))
+
+	buff, err := transport.Read()
+
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buff))
+
+	var _ tun4go.ContextTransport = transport
+}
+
+func TestCloseBlockedWrite(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// peer never reads, so writes block once the socket buffers are full
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		<-release
+	}))
+	defer server.Close()
+
+	transport, err := Dial(server.URL, WithPing(0, 0))
+
+	require.NoError(t, err)
+
+	writeErr := make(chan error, 1)
+
+	go func() {
+		buff := make([]byte, 1024*1024)
+
+		for {
+			if err := transport.Write(buff); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	closed := make(chan struct{})
+
+	go func() {
+		transport.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "close blocked by pending write")
+	}
+
+	select {
+	case err := <-writeErr:
+		require.Error(t, err)
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "write not unblocked by close")
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	server := newEchoServer(false)
+	defer server.Close()
+
+	transport, err := Dial(server.URL, WithQueueLimit(2))
+
+	require.NoError(t, err)
+
+	defer transport.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, transport.Write([]byte("hello")))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		buff, err := transport.Read()
+
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buff))
+	}
+
+	_, err = transport.Read()
+
+	require.True(t, errors.Is(err, ErrQueueFull))
+
+	require.True(t, errors.Is(transport.Write([]byte("hello")), ErrClosed))
+}
@@ -4,7 +4,6 @@ import (
 	_ "github.com/google/uuid"       //
 	_ "github.com/gorilla/websocket" //
 	"github.com/libs4go/errors"
-	_ "github.com/libs4go/errors"   //
 	_ "github.com/libs4go/scf4go"   //
 	_ "github.com/libs4go/slf4go"   //
 	_ "github.com/stretchr/testify" //
@@ -31,6 +30,23 @@ type Tunnel interface {
 	Context() ([]byte, error)
 }
 
+// Status Tunnel status
+type Status string
+
+// Status enum
+const (
+	Connecting    Status = "connecting"
+	Connected     Status = "connected"
+	Disconnecting Status = "disconnecting"
+	Disconnected  Status = "disconnected"
+)
+
+// StatusTunnel Tunnel object with status query function
+type StatusTunnel interface {
+	Tunnel
+	Status() Status
+}
+
 // TunnelCloser Tunnel object with close function
 type TunnelCloser interface {
 	Tunnel
@@ -60,23 +76,23 @@ type Provider interface {
 	New(params Params) (Tunnel, error)
 }
 
-// New create new tunnel
+// New create new tunnel, returns ErrProviderNotFound if provider not registered
 func New(name string, params Params) (Tunnel, error) {
-	var provider Provider
+	provider, ok := LookupProvider(name)
 
-	if err := getProvider(name, &provider); err != nil {
-		panic(errors.Wrap(err, "provider with name %s not found, call RegisterProvider first", name))
+	if !ok {
+		return nil, errors.Wrap(ErrProviderNotFound, "provider with name %s not found, call RegisterProvider first", name)
 	}
 
 	return provider.New(params)
 }
 
-// FromContext create tunnel with context
+// FromContext create tunnel with context, returns ErrProviderNotFound if provider not registered
 func FromContext(name string, context []byte) (Tunnel, error) {
-	var provider Provider
+	provider, ok := LookupProvider(name)
 
-	if err := getProvider(name, &provider); err != nil {
-		panic(errors.Wrap(err, "provider with name %s not found, call RegisterProvider first", name))
+	if !ok {
+		return nil, errors.Wrap(ErrProviderNotFound, "provider with name %s not found, call RegisterProvider first", name)
 	}
 
 	return provider.FromContext(context)